@@ -0,0 +1,2 @@
+// Package agevault provides a storage in a file encrypted to age recipients.
+package agevault
@@ -0,0 +1,437 @@
+package agevault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/internal/fsutil"
+)
+
+var _ moneyloverkeychain.Storage = (*Storage)(nil)
+
+// ErrNoRecipients indicates that the vault would not be encrypted to any recipient.
+var ErrNoRecipients = errors.New("no recipients")
+
+// ErrNoIdentities indicates that there is no identity to decrypt the vault.
+var ErrNoIdentities = errors.New("no identities")
+
+// ErrUntrustedRecipients indicates that the recipients of the vault are not the trusted recipients.
+var ErrUntrustedRecipients = errors.New("recipients of the vault are not trusted")
+
+// Option configures Storage.
+type Option func(s *Storage)
+
+// vault is the content of the vault file.
+type vault struct {
+	Recipients []string                     `json:"recipients"`
+	Entries    map[string]map[string]string `json:"entries"`
+}
+
+// Storage is a storage in a file encrypted to a set of age recipients. Every recipient can decrypt the vault with their
+// identity, and every write re-encrypts the vault to the same recipients.
+//
+// age does not authenticate the sender, and the recipients are public keys. Anyone who can write the file can replace
+// it with a vault of their own, encrypted to the recipients and to themselves. With WithRecipients, the storage refuses
+// to write a vault whose recipients are not the trusted ones. Without it, the storage trusts the recipients listed in
+// the vault, so the next write re-encrypts every secret to the added recipients. In both cases, the secrets are only as
+// trustworthy as the file permissions.
+type Storage struct {
+	mu sync.Mutex
+
+	path       string
+	service    string
+	identities []age.Identity
+	recipients []string
+}
+
+// Set sets password in the vault for user.
+func (s *Storage) Set(user, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := fsutil.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	defer unlock() // nolint: errcheck
+
+	v, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkRecipients(v); err != nil {
+		return err
+	}
+
+	if v.Entries[s.service] == nil {
+		v.Entries[s.service] = make(map[string]string)
+	}
+
+	v.Entries[s.service][user] = password
+
+	return s.write(v)
+}
+
+// Get gets password from the vault.
+func (s *Storage) Get(user string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, err := s.read()
+	if err != nil {
+		return "", err
+	}
+
+	password, ok := v.Entries[s.service][user]
+	if !ok {
+		return "", moneyloverkeychain.ErrNotFound
+	}
+
+	return password, nil
+}
+
+// Delete deletes secret from the vault.
+func (s *Storage) Delete(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := fsutil.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	defer unlock() // nolint: errcheck
+
+	v, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkRecipients(v); err != nil {
+		return err
+	}
+
+	if _, ok := v.Entries[s.service][user]; !ok {
+		return moneyloverkeychain.ErrNotFound
+	}
+
+	delete(v.Entries[s.service], user)
+
+	if len(v.Entries[s.service]) == 0 {
+		delete(v.Entries, s.service)
+	}
+
+	return s.write(v)
+}
+
+// Recipients returns the recipients of the vault.
+func (s *Storage) Recipients() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Recipients, nil
+}
+
+// AddRecipients adds recipients to the vault and re-encrypts it.
+func (s *Storage) AddRecipients(recipients ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := fsutil.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	defer unlock() // nolint: errcheck
+
+	v, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkRecipients(v); err != nil {
+		return err
+	}
+
+	for _, r := range recipients {
+		if _, err := parseRecipient(r); err != nil {
+			return err
+		}
+
+		if indexRecipient(v.Recipients, r) < 0 {
+			v.Recipients = append(v.Recipients, r)
+		}
+	}
+
+	return s.writeRecipients(v)
+}
+
+// RemoveRecipients removes recipients from the vault and re-encrypts it. The removed recipients can not decrypt the new
+// vault, but they might still have a copy of the secrets so they should be rotated.
+func (s *Storage) RemoveRecipients(recipients ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := fsutil.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	defer unlock() // nolint: errcheck
+
+	v, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkRecipients(v); err != nil {
+		return err
+	}
+
+	for _, r := range recipients {
+		if i := indexRecipient(v.Recipients, r); i >= 0 {
+			v.Recipients = append(v.Recipients[:i], v.Recipients[i+1:]...)
+		}
+	}
+
+	return s.writeRecipients(v)
+}
+
+// checkRecipients refuses a vault whose recipients are not the trusted recipients set by WithRecipients.
+func (s *Storage) checkRecipients(v vault) error {
+	if len(s.recipients) == 0 || sameRecipients(v.Recipients, s.recipients) {
+		return nil
+	}
+
+	return ErrUntrustedRecipients
+}
+
+// writeRecipients writes the vault with new recipients and trusts them.
+func (s *Storage) writeRecipients(v vault) error {
+	if err := s.write(v); err != nil {
+		return err
+	}
+
+	if len(s.recipients) > 0 {
+		s.recipients = append([]string(nil), v.Recipients...)
+	}
+
+	return nil
+}
+
+// lockPath returns the path of the file that is locked while writing, so that the processes sharing the vault do not
+// lose each other's changes.
+func (s *Storage) lockPath() string {
+	return s.path + ".lock"
+}
+
+// read reads and decrypts the vault. If the vault does not exist, a new one is returned.
+func (s *Storage) read() (vault, error) {
+	data, err := os.ReadFile(filepath.Clean(s.path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return vault{
+				Recipients: s.initialRecipients(),
+				Entries:    make(map[string]map[string]string),
+			}, nil
+		}
+
+		return vault{}, err
+	}
+
+	if len(s.identities) == 0 {
+		return vault{}, ErrNoIdentities
+	}
+
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), s.identities...)
+	if err != nil {
+		return vault{}, fmt.Errorf("could not decrypt vault: %w", err)
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return vault{}, fmt.Errorf("could not decrypt vault: %w", err)
+	}
+
+	var v vault
+
+	if err := json.Unmarshal(plaintext, &v); err != nil {
+		return vault{}, fmt.Errorf("%w: %s", moneyloverkeychain.ErrInvalidVault, err.Error())
+	}
+
+	if v.Entries == nil {
+		v.Entries = make(map[string]map[string]string)
+	}
+
+	return v, nil
+}
+
+// write encrypts the vault to its recipients and writes it atomically.
+func (s *Storage) write(v vault) error {
+	if len(v.Recipients) == 0 {
+		return ErrNoRecipients
+	}
+
+	recipients := make([]age.Recipient, 0, len(v.Recipients))
+
+	for _, r := range v.Recipients {
+		recipient, err := parseRecipient(r)
+		if err != nil {
+			return err
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	a := armor.NewWriter(&buf)
+
+	w, err := age.Encrypt(a, recipients...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := a.Close(); err != nil {
+		return err
+	}
+
+	return fsutil.WriteFileAtomic(s.path, buf.Bytes())
+}
+
+// initialRecipients returns the recipients of a new vault. If there is no configured recipient, the vault is encrypted
+// to the X25519 identities.
+func (s *Storage) initialRecipients() []string {
+	if len(s.recipients) > 0 {
+		return append([]string(nil), s.recipients...)
+	}
+
+	recipients := make([]string, 0, len(s.identities))
+
+	for _, i := range s.identities {
+		if x, ok := i.(*age.X25519Identity); ok {
+			recipients = append(recipients, x.Recipient().String())
+		}
+	}
+
+	return recipients
+}
+
+func parseRecipient(s string) (age.Recipient, error) {
+	if strings.HasPrefix(s, "age1") {
+		return age.ParseX25519Recipient(s)
+	}
+
+	return agessh.ParseRecipient(s)
+}
+
+// indexRecipient finds a recipient in a list. SSH recipients are compared without their comments.
+func indexRecipient(recipients []string, recipient string) int {
+	recipient = normalizeRecipient(recipient)
+
+	for i, r := range recipients {
+		if normalizeRecipient(r) == recipient {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// sameRecipients reports whether the lists have the same recipients, in any order.
+func sameRecipients(a, b []string) bool {
+	for _, r := range a {
+		if indexRecipient(b, r) < 0 {
+			return false
+		}
+	}
+
+	for _, r := range b {
+		if indexRecipient(a, r) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func normalizeRecipient(s string) string {
+	fields := strings.Fields(s)
+
+	if len(fields) > 2 {
+		fields = fields[:2]
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// New creates a storage in a file encrypted to age recipients.
+//
+// The vault is created on the first write and is encrypted to the recipients set by WithRecipients, or to the X25519
+// identities if there is none. The writes lock a file next to the vault with the ".lock" suffix.
+func New(path string, options ...Option) *Storage {
+	s := &Storage{
+		path: path,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithService sets the service of the storage so that several storages can share the same vault.
+func WithService(service string) Option {
+	return func(s *Storage) {
+		s.service = service
+	}
+}
+
+// WithIdentities sets the identities for decrypting the vault.
+func WithIdentities(identities ...age.Identity) Option {
+	return func(s *Storage) {
+		s.identities = append(s.identities, identities...)
+	}
+}
+
+// WithRecipients sets the recipients of a new vault, and the trusted recipients of an existing vault. The writes fail
+// with ErrUntrustedRecipients if the vault is encrypted to other recipients. The trusted recipients are changed by
+// Storage.AddRecipients and Storage.RemoveRecipients of the same storage.
+func WithRecipients(recipients ...string) Option {
+	return func(s *Storage) {
+		s.recipients = append(s.recipients, recipients...)
+	}
+}
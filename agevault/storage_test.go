@@ -0,0 +1,330 @@
+package agevault_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/agevault"
+)
+
+func newIdentity(t *testing.T) *age.X25519Identity {
+	t.Helper()
+
+	i, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	return i
+}
+
+func newSSHIdentity(t *testing.T) (age.Identity, string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	i, err := agessh.NewEd25519Identity(priv)
+	require.NoError(t, err)
+
+	pk, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+
+	return i, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pk))) + " ci@example.org"
+}
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+	s := agevault.New(path, agevault.WithIdentities(newIdentity(t)))
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestStorage_Recipients(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+
+	alice := newIdentity(t)
+	bob := newIdentity(t)
+	ci, ciRecipient := newSSHIdentity(t)
+
+	aliceStorage := agevault.New(path,
+		agevault.WithIdentities(alice),
+		agevault.WithRecipients(alice.Recipient().String(), bob.Recipient().String()),
+	)
+	bobStorage := agevault.New(path, agevault.WithIdentities(bob))
+	ciStorage := agevault.New(path, agevault.WithIdentities(ci))
+
+	err := aliceStorage.Set("test", "foobar")
+	require.NoError(t, err)
+
+	// Bob can read.
+	data, err := bobStorage.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// CI can not read.
+	_, err = ciStorage.Get("test")
+
+	require.Error(t, err)
+
+	// Bob adds CI.
+	err = bobStorage.AddRecipients(ciRecipient)
+	require.NoError(t, err)
+
+	data, err = ciStorage.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// CI writes, everyone can read.
+	err = ciStorage.Set("test", "foobaz")
+	require.NoError(t, err)
+
+	data, err = aliceStorage.Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+
+	recipients, err := aliceStorage.Recipients()
+	require.NoError(t, err)
+
+	expected := []string{alice.Recipient().String(), bob.Recipient().String(), ciRecipient}
+
+	assert.Equal(t, expected, recipients)
+
+	// Alice does not trust CI yet.
+	err = aliceStorage.Set("test", "foobar")
+
+	assert.ErrorIs(t, err, agevault.ErrUntrustedRecipients)
+
+	aliceStorage = agevault.New(path,
+		agevault.WithIdentities(alice),
+		agevault.WithRecipients(expected...),
+	)
+
+	// Alice removes Bob.
+	err = aliceStorage.RemoveRecipients(bob.Recipient().String())
+	require.NoError(t, err)
+
+	_, err = bobStorage.Get("test")
+
+	var noMatch *age.NoIdentityMatchError
+
+	assert.ErrorAs(t, err, &noMatch)
+
+	data, err = ciStorage.Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+
+	// Alice removes the others without the comment of the ssh key.
+	err = aliceStorage.RemoveRecipients(strings.Join(strings.Fields(ciRecipient)[:2], " "), alice.Recipient().String())
+
+	assert.ErrorIs(t, err, agevault.ErrNoRecipients)
+}
+
+func TestStorage_UntrustedRecipients(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+
+	alice := newIdentity(t)
+	mallory := newIdentity(t)
+
+	s := agevault.New(path,
+		agevault.WithIdentities(alice),
+		agevault.WithRecipients(alice.Recipient().String()),
+	)
+
+	require.NoError(t, s.Set("test", "foobar"))
+
+	// Mallory replaces the vault with one that is also encrypted to them.
+	forged := path + ".forged"
+
+	err := agevault.New(forged,
+		agevault.WithIdentities(mallory),
+		agevault.WithRecipients(alice.Recipient().String(), mallory.Recipient().String()),
+	).Set("test", "foobar")
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(forged, path))
+
+	// Alice does not write her secrets to Mallory.
+	err = s.Set("test", "foobaz")
+
+	assert.ErrorIs(t, err, agevault.ErrUntrustedRecipients)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, agevault.ErrUntrustedRecipients)
+
+	err = s.AddRecipients(mallory.Recipient().String())
+
+	assert.ErrorIs(t, err, agevault.ErrUntrustedRecipients)
+
+	// Mallory does not get the new secret.
+	data, err := agevault.New(path, agevault.WithIdentities(mallory)).Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+}
+
+func TestStorage_TrustAddedRecipients(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+
+	alice := newIdentity(t)
+	bob := newIdentity(t)
+
+	s := agevault.New(path,
+		agevault.WithIdentities(alice),
+		agevault.WithRecipients(alice.Recipient().String()),
+	)
+
+	require.NoError(t, s.Set("test", "foobar"))
+	require.NoError(t, s.AddRecipients(bob.Recipient().String()))
+	require.NoError(t, s.Set("test", "foobaz"))
+
+	data, err := agevault.New(path, agevault.WithIdentities(bob)).Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+}
+
+func TestStorage_InvalidRecipient(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+	s := agevault.New(path, agevault.WithRecipients("age1invalid"))
+
+	err := s.Set("test", "foobar")
+
+	require.Error(t, err)
+
+	err = agevault.New(path, agevault.WithIdentities(newIdentity(t))).AddRecipients("ssh-invalid")
+
+	require.Error(t, err)
+}
+
+func TestStorage_NoRecipients(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+
+	err := agevault.New(path).Set("test", "foobar")
+
+	assert.ErrorIs(t, err, agevault.ErrNoRecipients)
+}
+
+func TestStorage_NoIdentities(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+	identity := newIdentity(t)
+
+	err := agevault.New(path, agevault.WithIdentities(identity)).Set("test", "foobar")
+	require.NoError(t, err)
+
+	_, err = agevault.New(path).Get("test")
+
+	assert.ErrorIs(t, err, agevault.ErrNoIdentities)
+}
+
+func TestStorage_Services(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+	identity := newIdentity(t)
+
+	credentials := agevault.New(path, agevault.WithIdentities(identity), agevault.WithService("credentials"))
+	token := agevault.New(path, agevault.WithIdentities(identity), agevault.WithService("token"))
+
+	require.NoError(t, credentials.Set("test", "credentials"))
+	require.NoError(t, token.Set("test", "token"))
+
+	data, err := credentials.Get("test")
+
+	assert.Equal(t, "credentials", data)
+	require.NoError(t, err)
+
+	data, err = token.Get("test")
+
+	assert.Equal(t, "token", data)
+	require.NoError(t, err)
+}
+
+func TestStorage_ConcurrentProcesses(t *testing.T) {
+	t.Parallel()
+
+	const writers = 4
+
+	path := filepath.Join(t.TempDir(), "vault.age")
+	identity := newIdentity(t)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		// Every writer has its own storage, like separate processes.
+		s := agevault.New(path, agevault.WithIdentities(identity), agevault.WithService(fmt.Sprintf("service%d", i)))
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, s.Set("test", "foobar"))
+		}()
+	}
+
+	wg.Wait()
+
+	for i := 0; i < writers; i++ {
+		data, err := agevault.New(path, agevault.WithIdentities(identity), agevault.WithService(fmt.Sprintf("service%d", i))).
+			Get("test")
+
+		assert.Equal(t, "foobar", data)
+		assert.NoError(t, err)
+	}
+}
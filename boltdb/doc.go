@@ -0,0 +1,2 @@
+// Package boltdb provides a storage in a bbolt database with encrypted values.
+package boltdb
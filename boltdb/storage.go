@@ -0,0 +1,144 @@
+package boltdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/internal/seal"
+)
+
+var _ moneyloverkeychain.Storage = (*Storage)(nil)
+
+// ErrNoKeySource indicates that the storage has no encryption key.
+var ErrNoKeySource = errors.New("no encryption key source")
+
+// Storage is a storage in a bbolt database. Each service is a bucket, and the values are sealed with AES-256-GCM. The
+// service and the key are authenticated with the value so a sealed value can not be moved to another key.
+type Storage struct {
+	db      *bbolt.DB
+	ownDB   bool
+	service string
+
+	keySource moneyloverkeychain.KeySource
+}
+
+// Set sets password in the database for user.
+func (s *Storage) Set(user, password string) error {
+	key, err := s.encryptionKey()
+	if err != nil {
+		return err
+	}
+
+	value, err := seal.Seal(key, []byte(password), seal.AdditionalData(s.service, user))
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(s.service))
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(user), value)
+	})
+}
+
+// Get gets password from the database.
+func (s *Storage) Get(user string) (string, error) {
+	key, err := s.encryptionKey()
+	if err != nil {
+		return "", err
+	}
+
+	var value []byte
+
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(s.service))
+		if b == nil {
+			return moneyloverkeychain.ErrNotFound
+		}
+
+		v := b.Get([]byte(user))
+		if v == nil {
+			return moneyloverkeychain.ErrNotFound
+		}
+
+		// The value is only valid during the transaction.
+		value = append([]byte(nil), v...)
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	password, err := seal.Open(key, value, seal.AdditionalData(s.service, user))
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt value: %w", err)
+	}
+
+	return string(password), nil
+}
+
+// Delete deletes secret from the database.
+func (s *Storage) Delete(user string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(s.service))
+		if b == nil || b.Get([]byte(user)) == nil {
+			return moneyloverkeychain.ErrNotFound
+		}
+
+		return b.Delete([]byte(user))
+	})
+}
+
+// Close closes the database if it was opened by Open.
+func (s *Storage) Close() error {
+	if !s.ownDB {
+		return nil
+	}
+
+	return s.db.Close()
+}
+
+// encryptionKey returns the key, which is loaded on the first use.
+func (s *Storage) encryptionKey() ([]byte, error) {
+	if s.keySource == nil {
+		return nil, ErrNoKeySource
+	}
+
+	return s.keySource()
+}
+
+// New creates a storage in an opened database. The database is not closed by Close.
+func New(db *bbolt.DB, service string, key moneyloverkeychain.KeySource) *Storage {
+	s := &Storage{
+		db:      db,
+		service: service,
+	}
+
+	if key != nil {
+		s.keySource = moneyloverkeychain.CachedKey(key)
+	}
+
+	return s
+}
+
+// Open opens or creates the database file and creates a storage in it. The database is locked until Close is called.
+func Open(path, service string, key moneyloverkeychain.KeySource) (*Storage, error) {
+	db, err := bbolt.Open(path, os.FileMode(0o600), &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := New(db, service, key)
+	s.ownDB = true
+
+	return s, nil
+}
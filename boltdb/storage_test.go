@@ -0,0 +1,191 @@
+package boltdb_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/boltdb"
+)
+
+var testKey = moneyloverkeychain.StaticKey([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "secrets.db")
+
+	s, err := boltdb.Open(path, "service", testKey)
+	require.NoError(t, err)
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Reopen.
+	require.NoError(t, s.Close())
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(content), "foobar")
+
+	s, err = boltdb.Open(path, "service", testKey)
+	require.NoError(t, err)
+
+	defer s.Close() // nolint: errcheck
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestStorage_Services(t *testing.T) {
+	t.Parallel()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "secrets.db"), 0o600, nil)
+	require.NoError(t, err)
+
+	defer db.Close() // nolint: errcheck
+
+	credentials := boltdb.New(db, "credentials", testKey)
+	token := boltdb.New(db, "token", testKey)
+
+	require.NoError(t, credentials.Set("test", "credentials"))
+	require.NoError(t, token.Set("test", "token"))
+
+	data, err := credentials.Get("test")
+
+	assert.Equal(t, "credentials", data)
+	require.NoError(t, err)
+
+	require.NoError(t, token.Delete("test"))
+
+	data, err = credentials.Get("test")
+
+	assert.Equal(t, "credentials", data)
+	require.NoError(t, err)
+
+	// The storage does not close a database it did not open.
+	require.NoError(t, credentials.Close())
+
+	data, err = token.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestStorage_MovedValue(t *testing.T) {
+	t.Parallel()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "secrets.db"), 0o600, nil)
+	require.NoError(t, err)
+
+	defer db.Close() // nolint: errcheck
+
+	s := boltdb.New(db, "service", testKey)
+
+	require.NoError(t, s.Set("foo", "foobar"))
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("service"))
+
+		return b.Put([]byte("bar"), b.Get([]byte("foo")))
+	})
+	require.NoError(t, err)
+
+	data, err := s.Get("bar")
+
+	assert.Empty(t, data)
+	require.EqualError(t, err, "could not decrypt value: cipher: message authentication failed")
+}
+
+func TestStorage_WrongKey(t *testing.T) {
+	t.Parallel()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "secrets.db"), 0o600, nil)
+	require.NoError(t, err)
+
+	defer db.Close() // nolint: errcheck
+
+	require.NoError(t, boltdb.New(db, "service", testKey).Set("test", "foobar"))
+
+	wrongKey := moneyloverkeychain.StaticKey([]byte("fedcba9876543210fedcba9876543210"))
+
+	data, err := boltdb.New(db, "service", wrongKey).Get("test")
+
+	assert.Empty(t, data)
+	require.EqualError(t, err, "could not decrypt value: cipher: message authentication failed")
+}
+
+func TestStorage_InvalidKey(t *testing.T) {
+	t.Parallel()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "secrets.db"), 0o600, nil)
+	require.NoError(t, err)
+
+	defer db.Close() // nolint: errcheck
+
+	err = boltdb.New(db, "service", moneyloverkeychain.StaticKey([]byte("short"))).Set("test", "foobar")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrInvalidEncryptionKey)
+
+	err = boltdb.New(db, "service", nil).Set("test", "foobar")
+
+	assert.ErrorIs(t, err, boltdb.ErrNoKeySource)
+}
+
+func TestStorage_KeyNotAvailableYet(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+
+	s, err := boltdb.Open(filepath.Join(dir, "secrets.db"), "service", moneyloverkeychain.KeyFromFile(keyFile))
+	require.NoError(t, err)
+
+	defer s.Close() // nolint: errcheck
+
+	err = s.Set("test", "foobar")
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// The key is read again once it is available.
+	require.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0o600))
+	require.NoError(t, s.Set("test", "foobar"))
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+}
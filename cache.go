@@ -0,0 +1,276 @@
+package moneyloverkeychain
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"go.nhat.io/clock"
+)
+
+const (
+	// DefaultCacheTTL is the default time to live of a cached secret.
+	DefaultCacheTTL = time.Minute
+	// DefaultCacheSize is the default maximum number of cached entries.
+	DefaultCacheSize = 128
+)
+
+var _ Storage = (*CachedStorage)(nil)
+
+// CachedStorageOption configures CachedStorage.
+type CachedStorageOption func(s *CachedStorage)
+
+// CacheStats are the counters of a CachedStorage.
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+type cacheEntry struct {
+	user      string
+	password  string
+	found     bool
+	expiresAt time.Time
+}
+
+// CachedStorage is a storage that caches the secrets of the upstream storage in memory.
+type CachedStorage struct {
+	upstream Storage
+	clock    clock.Clock
+
+	ttl         time.Duration
+	negativeTTL time.Duration
+	size        int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	stats   CacheStats
+
+	// version changes on every write so that a Get racing with a write does not cache a stale secret.
+	version uint64
+}
+
+// Set sets password in the upstream storage and updates the cache.
+func (s *CachedStorage) Set(user, password string) error {
+	version := s.beginWrite()
+	err := s.upstream.Set(user, password)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil || !s.endWrite(version) {
+		s.remove(user)
+
+		return err
+	}
+
+	s.store(user, password, true)
+
+	return nil
+}
+
+// Get gets password from the cache, or from the upstream storage if it is not cached or expired.
+func (s *CachedStorage) Get(user string) (string, error) {
+	s.mu.Lock()
+
+	if e, ok := s.entries[user]; ok {
+		entry := e.Value.(*cacheEntry) // nolint: forcetypeassert
+
+		if s.clock.Now().Before(entry.expiresAt) {
+			s.stats.Hits++
+			s.lru.MoveToFront(e)
+			s.mu.Unlock()
+
+			if !entry.found {
+				return "", ErrNotFound
+			}
+
+			return entry.password, nil
+		}
+
+		s.remove(user)
+	}
+
+	s.stats.Misses++
+	version := s.version
+	s.mu.Unlock()
+
+	password, err := s.upstream.Get(user)
+	found := err == nil
+
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if version == s.version {
+		s.store(user, password, found)
+	}
+
+	return password, err
+}
+
+// Delete deletes secret from the upstream storage and updates the cache.
+func (s *CachedStorage) Delete(user string) error {
+	version := s.beginWrite()
+	err := s.upstream.Delete(user)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if (err != nil && !errors.Is(err, ErrNotFound)) || !s.endWrite(version) {
+		s.remove(user)
+
+		return err
+	}
+
+	s.store(user, "", false)
+
+	return err
+}
+
+// beginWrite changes the version before writing to the upstream storage so that a Get racing with the write does not
+// cache the secret.
+func (s *CachedStorage) beginWrite() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.version++
+
+	return s.version
+}
+
+// endWrite changes the version after writing to the upstream storage, and reports whether no other write happened
+// since beginWrite. Otherwise, the order of the writes in the upstream storage is unknown and the secret must not be
+// cached. The lock must be held.
+func (s *CachedStorage) endWrite(version uint64) bool {
+	ok := s.version == version
+	s.version++
+
+	return ok
+}
+
+// Invalidate removes the users from the cache.
+func (s *CachedStorage) Invalidate(users ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.version++
+
+	for _, user := range users {
+		s.remove(user)
+	}
+}
+
+// InvalidateAll removes everything from the cache.
+func (s *CachedStorage) InvalidateAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.version++
+	s.entries = make(map[string]*list.Element)
+	s.lru.Init()
+}
+
+// Stats returns the hit and miss counters.
+func (s *CachedStorage) Stats() CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+// store caches the secret, or the absence of it, and evicts the least recently used entries.
+func (s *CachedStorage) store(user, password string, found bool) {
+	ttl := s.ttl
+
+	if !found {
+		ttl = s.negativeTTL
+	}
+
+	if ttl <= 0 {
+		s.remove(user)
+
+		return
+	}
+
+	entry := &cacheEntry{
+		user:      user,
+		password:  password,
+		found:     found,
+		expiresAt: s.clock.Now().Add(ttl),
+	}
+
+	if e, ok := s.entries[user]; ok {
+		e.Value = entry
+		s.lru.MoveToFront(e)
+
+		return
+	}
+
+	s.entries[user] = s.lru.PushFront(entry)
+
+	for s.lru.Len() > s.size {
+		s.remove(s.lru.Back().Value.(*cacheEntry).user) // nolint: forcetypeassert
+	}
+}
+
+func (s *CachedStorage) remove(user string) {
+	if e, ok := s.entries[user]; ok {
+		s.lru.Remove(e)
+		delete(s.entries, user)
+	}
+}
+
+// NewCachedStorage creates a storage that caches the secrets of the upstream storage for DefaultCacheTTL. The writes go
+// through to the upstream storage. The absence of a secret is not cached unless WithNegativeCacheTTL is used.
+func NewCachedStorage(upstream Storage, options ...CachedStorageOption) *CachedStorage {
+	s := &CachedStorage{
+		upstream: upstream,
+		clock:    clock.New(),
+		ttl:      DefaultCacheTTL,
+		size:     DefaultCacheSize,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithCacheTTL sets the time to live of a cached secret.
+func WithCacheTTL(ttl time.Duration) CachedStorageOption {
+	return func(s *CachedStorage) {
+		s.ttl = ttl
+	}
+}
+
+// WithNegativeCacheTTL sets the time to live of the absence of a secret.
+func WithNegativeCacheTTL(ttl time.Duration) CachedStorageOption {
+	return func(s *CachedStorage) {
+		s.negativeTTL = ttl
+	}
+}
+
+// WithCacheSize sets the maximum number of cached entries.
+func WithCacheSize(size int) CachedStorageOption {
+	return func(s *CachedStorage) {
+		if size > 0 {
+			s.size = size
+		}
+	}
+}
+
+// WithCacheClock sets the clock of the cache.
+func WithCacheClock(c clock.Clock) CachedStorageOption {
+	return func(s *CachedStorage) {
+		s.clock = c
+	}
+}
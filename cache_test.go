@@ -0,0 +1,276 @@
+package moneyloverkeychain_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func TestCachedStorage(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewCachedStorage(upstream)
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = upstream.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	assert.Empty(t, upstream.Snapshot())
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	assert.Equal(t, moneyloverkeychain.CacheStats{Hits: 1, Misses: 2}, s.Stats())
+}
+
+func TestCachedStorage_TTL(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClock()
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewCachedStorage(upstream,
+		moneyloverkeychain.WithCacheTTL(time.Minute),
+		moneyloverkeychain.WithNegativeCacheTTL(10*time.Second),
+		moneyloverkeychain.WithCacheClock(c),
+	)
+
+	require.NoError(t, upstream.Set("test", "foobar"))
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// The change in the upstream storage is not seen until the secret expires.
+	require.NoError(t, upstream.Set("test", "foobaz"))
+
+	c.Add(59 * time.Second)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	c.Add(time.Second)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+
+	// Negative.
+	_, err = s.Get("unknown")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	require.NoError(t, upstream.Set("unknown", "foobar"))
+
+	_, err = s.Get("unknown")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	c.Add(10 * time.Second)
+
+	data, err = s.Get("unknown")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, moneyloverkeychain.CacheStats{Hits: 2, Misses: 4}, s.Stats())
+}
+
+func TestCachedStorage_Size(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewCachedStorage(upstream, moneyloverkeychain.WithCacheSize(2))
+
+	require.NoError(t, s.Set("a", "a"))
+	require.NoError(t, s.Set("b", "b"))
+
+	// "a" is the most recently used.
+	_, err := s.Get("a")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Set("c", "c"))
+
+	for _, user := range []string{"a", "b", "c"} {
+		_, err := s.Get(user)
+		require.NoError(t, err)
+	}
+
+	// "b" was evicted, and then "c" after "b" was cached again.
+	assert.Equal(t, moneyloverkeychain.CacheStats{Hits: 2, Misses: 2}, s.Stats())
+}
+
+func TestCachedStorage_Invalidate(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewCachedStorage(upstream)
+
+	require.NoError(t, s.Set("a", "a"))
+	require.NoError(t, s.Set("b", "b"))
+	require.NoError(t, upstream.Set("a", "new a"))
+	require.NoError(t, upstream.Set("b", "new b"))
+
+	s.Invalidate("a")
+
+	data, err := s.Get("a")
+
+	assert.Equal(t, "new a", data)
+	require.NoError(t, err)
+
+	data, err = s.Get("b")
+
+	assert.Equal(t, "b", data)
+	require.NoError(t, err)
+
+	s.InvalidateAll()
+
+	data, err = s.Get("b")
+
+	assert.Equal(t, "new b", data)
+	require.NoError(t, err)
+}
+
+func TestCachedStorage_UpstreamError(t *testing.T) {
+	t.Parallel()
+
+	upstream := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Get", "test").Return("", errors.New("get error")).Once()
+		s.On("Get", "test").Return("foobar", nil).Once()
+		s.On("Set", "test", "foobaz").Return(errors.New("set error")).Once()
+		s.On("Get", "test").Return("foobar", nil).Once()
+	})(t)
+
+	s := moneyloverkeychain.NewCachedStorage(upstream)
+
+	// Errors are not cached.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	require.EqualError(t, err, "get error")
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// A failed write invalidates the cache.
+	require.EqualError(t, s.Set("test", "foobaz"), "set error")
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+}
+
+// slowStorage blocks after writing a password until it is released.
+type slowStorage struct {
+	moneyloverkeychain.Storage
+
+	password string
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func (s *slowStorage) Set(user, password string) error {
+	err := s.Storage.Set(user, password)
+
+	if password == s.password {
+		close(s.started)
+		<-s.release
+	}
+
+	return err
+}
+
+func TestCachedStorage_ConcurrentSet(t *testing.T) {
+	t.Parallel()
+
+	upstream := &slowStorage{
+		Storage:  moneyloverkeychain.NewMemoryStorage(),
+		password: "old",
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+
+	s := moneyloverkeychain.NewCachedStorage(upstream, moneyloverkeychain.WithCacheClock(newTestClock()))
+
+	done := make(chan error)
+
+	go func() {
+		done <- s.Set("test", "old")
+	}()
+
+	<-upstream.started
+
+	// The newer Set is written last, but the older one finishes last.
+	require.NoError(t, s.Set("test", "new"))
+
+	close(upstream.release)
+	require.NoError(t, <-done)
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "new", data)
+	require.NoError(t, err)
+}
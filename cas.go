@@ -0,0 +1,148 @@
+package moneyloverkeychain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nhatthm/moneyloverkeychain/internal/fsutil"
+)
+
+var (
+	_ ConditionalStorage = (*EnvelopeStorage)(nil)
+	_ Locker             = (*fileLocker)(nil)
+)
+
+// ConditionalStorage is a storage that writes a secret only if it is still at the expected revision, so that concurrent
+// writers detect the lost updates instead of overwriting each other.
+//
+// Revision 0 means that the secret does not exist, or it was written without metadata.
+type ConditionalStorage interface {
+	MetadataStorage
+
+	// SetIf sets password in keychain for user if the secret is at the revision, and returns the new metadata.
+	SetIf(user string, revision uint64, password string) (Metadata, error)
+	// DeleteIf deletes secret from keychain if it is at the revision.
+	DeleteIf(user string, revision uint64) error
+}
+
+// ConflictError is returned by a conditional write when the secret is not at the expected revision.
+type ConflictError struct {
+	Key      string
+	Expected uint64
+	Actual   uint64
+}
+
+// Error returns the error message.
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %q is at revision %d, expected %d", ErrConflict.Error(), e.Key, e.Actual, e.Expected)
+}
+
+// Is reports whether the target is ErrConflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict // nolint: errorlint
+}
+
+// Locker is a lock that can fail to be acquired, such as a lock shared with other processes.
+type Locker interface {
+	Lock() error
+	Unlock() error
+}
+
+type fileLocker struct {
+	mu sync.Mutex
+
+	path   string
+	unlock func() error
+}
+
+// Lock acquires the lock, waiting for the other goroutines and processes to release it.
+func (l *fileLocker) Lock() error {
+	l.mu.Lock()
+
+	unlock, err := fsutil.Lock(l.path)
+	if err != nil {
+		l.mu.Unlock()
+
+		return err
+	}
+
+	l.unlock = unlock
+
+	return nil
+}
+
+// Unlock releases the lock.
+func (l *fileLocker) Unlock() error {
+	defer l.mu.Unlock()
+
+	unlock := l.unlock
+	l.unlock = nil
+
+	return unlock()
+}
+
+// NewFileLocker creates a lock on a file that is shared by the processes using the same path, so that they do not
+// change the same secrets at the same time. The file and its directory are created if they do not exist, and the file
+// is never removed.
+func NewFileLocker(path string) Locker {
+	return &fileLocker{path: path}
+}
+
+// SetIf sets password in the upstream storage for user if the secret is at the revision. The labels are kept.
+func (s *EnvelopeStorage) SetIf(user string, revision uint64, password string) (Metadata, error) {
+	if err := s.lock(); err != nil {
+		return Metadata{}, err
+	}
+
+	defer s.unlock()
+
+	previous, err := s.current(user)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	if err := checkRevision(user, revision, previous); err != nil {
+		return Metadata{}, err
+	}
+
+	return s.write(user, password, nil, previous)
+}
+
+// DeleteIf deletes secret from the upstream storage if it is at the revision.
+func (s *EnvelopeStorage) DeleteIf(user string, revision uint64) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+
+	defer s.unlock()
+
+	previous, err := s.current(user)
+	if err != nil {
+		return err
+	}
+
+	if !previous.exists() {
+		return ErrNotFound
+	}
+
+	if err := checkRevision(user, revision, previous); err != nil {
+		return err
+	}
+
+	return s.delete(user, previous)
+}
+
+// checkRevision compares the revision with the revision of the secret, which is 0 if the secret does not exist.
+func checkRevision(user string, expected uint64, current *envelope) error {
+	var actual uint64
+
+	if current.exists() {
+		actual = current.Revision
+	}
+
+	if actual != expected {
+		return &ConflictError{Key: user, Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
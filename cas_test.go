@@ -0,0 +1,248 @@
+package moneyloverkeychain_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+func TestEnvelopeStorage_SetIf(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewEnvelopeStorage(moneyloverkeychain.NewMemoryStorage())
+
+	// Create.
+	meta, err := s.SetIf("test", 0, "foobar")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), meta.Revision)
+
+	// Conflict.
+	meta, err = s.SetIf("test", 0, "foobaz")
+
+	assert.Equal(t, moneyloverkeychain.Metadata{}, meta)
+	require.EqualError(t, err, `revision conflict: "test" is at revision 1, expected 0`)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrConflict)
+
+	var conflictErr *moneyloverkeychain.ConflictError
+
+	require.True(t, errors.As(err, &conflictErr))
+	assert.Equal(t, uint64(1), conflictErr.Actual)
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Update.
+	meta, err = s.SetIf("test", 1, "foobaz")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(2), meta.Revision)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+}
+
+func TestEnvelopeStorage_SetIfRecreated(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewEnvelopeStorage(moneyloverkeychain.NewMemoryStorage())
+
+	_, err := s.SetIf("test", 0, "foobar")
+	require.NoError(t, err)
+
+	// The first writer reads the secret.
+	_, meta, err := s.GetWithMeta("test")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), meta.Revision)
+
+	// Another writer deletes and recreates it.
+	require.NoError(t, s.DeleteIf("test", 1))
+
+	_, meta, err = s.GetWithMeta("test")
+
+	assert.Equal(t, moneyloverkeychain.Metadata{}, meta)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	meta, err = s.SetIf("test", 0, "foobaz")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(3), meta.Revision)
+
+	// The first writer does not overwrite the recreated secret.
+	_, err = s.SetIf("test", 1, "stale")
+
+	require.EqualError(t, err, `revision conflict: "test" is at revision 3, expected 1`)
+
+	err = s.DeleteIf("test", 1)
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrConflict)
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+}
+
+func TestEnvelopeStorage_SetIfLegacy(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewEnvelopeStorage(upstream)
+
+	require.NoError(t, upstream.Set("test", "foobar"))
+
+	meta, err := s.SetIf("test", 0, "foobaz")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), meta.Revision)
+}
+
+func TestEnvelopeStorage_DeleteIf(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewEnvelopeStorage(moneyloverkeychain.NewMemoryStorage())
+
+	// Delete not found.
+	err := s.DeleteIf("test", 0)
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Conflict.
+	_, err = s.SetWithMeta("test", "foobar", nil)
+	require.NoError(t, err)
+
+	err = s.DeleteIf("test", 2)
+
+	require.EqualError(t, err, `revision conflict: "test" is at revision 1, expected 2`)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrConflict)
+
+	// Delete.
+	err = s.DeleteIf("test", 1)
+	require.NoError(t, err)
+
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestEnvelopeStorage_Purge(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewEnvelopeStorage(upstream)
+
+	// Purge not found.
+	err := s.Purge("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// The tombstone is purged.
+	_, err = s.SetWithMeta("test", "foobar", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Delete("test"))
+	require.Len(t, upstream.Snapshot(), 1)
+
+	require.NoError(t, s.Purge("test"))
+	assert.Empty(t, upstream.Snapshot())
+
+	// The revision starts over.
+	meta, err := s.SetIf("test", 0, "foobaz")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), meta.Revision)
+
+	// A secret is purged without a tombstone.
+	require.NoError(t, s.Purge("test"))
+	assert.Empty(t, upstream.Snapshot())
+}
+
+func TestEnvelopeStorage_ConcurrentWriters(t *testing.T) {
+	t.Parallel()
+
+	const (
+		writers    = 4
+		increments = 20
+	)
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	path := filepath.Join(t.TempDir(), "lock")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		// Every writer has its own storage and lock, like separate processes.
+		s := moneyloverkeychain.NewEnvelopeStorage(upstream,
+			moneyloverkeychain.WithEnvelopeLocker(moneyloverkeychain.NewFileLocker(path)),
+		)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for n := 0; n < increments; {
+				data, meta, err := s.GetWithMeta("counter")
+				if err != nil && !errors.Is(err, moneyloverkeychain.ErrNotFound) {
+					assert.NoError(t, err)
+
+					return
+				}
+
+				counter, _ := strconv.Atoi(data) // nolint: errcheck
+
+				_, err = s.SetIf("counter", meta.Revision, strconv.Itoa(counter+1))
+				if errors.Is(err, moneyloverkeychain.ErrConflict) {
+					continue
+				}
+
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				n++
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	data, meta, err := moneyloverkeychain.NewEnvelopeStorage(upstream).GetWithMeta("counter")
+	require.NoError(t, err)
+
+	assert.Equal(t, strconv.Itoa(writers*increments), data)
+	assert.Equal(t, uint64(writers*increments), meta.Revision)
+}
+
+func TestEnvelopeStorage_LockError(t *testing.T) {
+	t.Parallel()
+
+	// The parent of the lock is a file.
+	parent := filepath.Join(t.TempDir(), "file")
+
+	require.NoError(t, os.WriteFile(parent, nil, 0o600))
+
+	locker := moneyloverkeychain.NewFileLocker(filepath.Join(parent, "lock"))
+	s := moneyloverkeychain.NewEnvelopeStorage(moneyloverkeychain.NewMemoryStorage(),
+		moneyloverkeychain.WithEnvelopeLocker(locker),
+	)
+
+	_, err := s.SetIf("test", 0, "foobar")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not acquire lock: ")
+}
@@ -0,0 +1,201 @@
+package moneyloverkeychain
+
+import "errors"
+
+// ChainErrorPolicy decides what a chain storage does when a storage fails with an error other than ErrNotFound or
+// ErrReadOnly.
+type ChainErrorPolicy int
+
+const (
+	// StopOnError returns the error right away.
+	StopOnError ChainErrorPolicy = iota
+	// ContinueOnError moves on to the next storage, the first error is returned if no storage succeeds.
+	ContinueOnError
+)
+
+var _ Storage = (*ChainStorage)(nil)
+
+// ChainStorageOption configures ChainStorage.
+type ChainStorageOption func(s *ChainStorage)
+
+// ChainStorage is a storage on top of several storages in priority order.
+type ChainStorage struct {
+	storages []Storage
+	policy   ChainErrorPolicy
+	backfill bool
+}
+
+// Set sets password in the first writable storage, the storages returning ErrReadOnly are skipped. When the password is
+// set in a fallback storage, it is deleted from the storages that failed, so that they do not shadow it with an older
+// password. The first error is returned if a storage still has the older password.
+func (s *ChainStorage) Set(user, password string) error {
+	var (
+		firstErr error
+		failed   []Storage
+	)
+
+	for _, st := range s.storages {
+		err := st.Set(user, password)
+		if err == nil {
+			return unshadow(user, failed, firstErr)
+		}
+
+		if errors.Is(err, ErrReadOnly) {
+			continue
+		}
+
+		if s.policy == StopOnError {
+			return err
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		failed = append(failed, st)
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ErrReadOnly
+}
+
+// Get gets password from the first storage that has it. With back-fill, a password found in a fallback storage is also
+// written to the primary storage.
+func (s *ChainStorage) Get(user string) (string, error) {
+	var firstErr error
+
+	for i, st := range s.storages {
+		password, err := st.Get(user)
+		if err == nil {
+			if i > 0 && s.backfill {
+				// The back-fill is best-effort, the password is returned even if the primary storage is broken.
+				_ = s.storages[0].Set(user, password) // nolint: errcheck
+			}
+
+			return password, nil
+		}
+
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+
+		if s.policy == StopOnError {
+			return "", err
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	return "", ErrNotFound
+}
+
+// Delete deletes secret from all the writable storages so that a fallback does not bring it back.
+func (s *ChainStorage) Delete(user string) error {
+	var (
+		firstErr error
+		deleted  bool
+		writable bool
+	)
+
+	for _, st := range s.storages {
+		err := st.Delete(user)
+
+		switch {
+		case err == nil:
+			deleted = true
+			writable = true
+
+			continue
+
+		case errors.Is(err, ErrReadOnly):
+			continue
+
+		case errors.Is(err, ErrNotFound):
+			writable = true
+
+			continue
+		}
+
+		if s.policy == StopOnError {
+			return err
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	switch {
+	case firstErr != nil:
+		return firstErr
+
+	case deleted:
+		return nil
+
+	case !writable:
+		return ErrReadOnly
+	}
+
+	return ErrNotFound
+}
+
+// unshadow deletes the secret from the storages that failed to set it. The error is returned if a storage can not delete
+// the secret and still has it.
+func unshadow(user string, storages []Storage, err error) error {
+	for _, st := range storages {
+		if deleteErr := st.Delete(user); deleteErr == nil || errors.Is(deleteErr, ErrNotFound) {
+			continue
+		}
+
+		if _, getErr := st.Get(user); getErr == nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Chain creates a chain storage with the default options. Use NewChainStorage to configure the chain, such as with
+// WithChainBackfill or WithChainErrorPolicy.
+func Chain(primary Storage, fallbacks ...Storage) *ChainStorage {
+	return NewChainStorage(append([]Storage{primary}, fallbacks...))
+}
+
+// NewChainStorage creates a storage that reads from the storages in priority order and writes to the first writable
+// one. A storage returning ErrNotFound is skipped, the other errors are handled by the ChainErrorPolicy, StopOnError
+// by default.
+func NewChainStorage(storages []Storage, options ...ChainStorageOption) *ChainStorage {
+	s := &ChainStorage{
+		storages: storages,
+		policy:   StopOnError,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithChainErrorPolicy sets the error policy of the chain storage.
+func WithChainErrorPolicy(policy ChainErrorPolicy) ChainStorageOption {
+	return func(s *ChainStorage) {
+		s.policy = policy
+	}
+}
+
+// WithChainBackfill writes the passwords found in a fallback storage to the primary storage.
+func WithChainBackfill() ChainStorageOption {
+	return func(s *ChainStorage) {
+		s.backfill = true
+	}
+}
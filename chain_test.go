@@ -0,0 +1,252 @@
+package moneyloverkeychain_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "readonly"), []byte("secret\n"), 0o600))
+
+	readonly := moneyloverkeychain.NewDirectoryStorage(dir)
+	primary := moneyloverkeychain.NewMemoryStorage()
+	fallback := moneyloverkeychain.NewMemoryStorage()
+
+	require.NoError(t, fallback.Set("fallback", "foobar"))
+
+	s := moneyloverkeychain.Chain(readonly, primary, fallback)
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Get from the read-only and the fallback storages.
+	data, err = s.Get("readonly")
+
+	assert.Equal(t, "secret", data)
+	require.NoError(t, err)
+
+	data, err = s.Get("fallback")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Set to the first writable storage.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"test": "foobar"}, primary.Snapshot())
+	assert.Equal(t, map[string]string{"fallback": "foobar"}, fallback.Snapshot())
+
+	// Delete from all the writable storages.
+	require.NoError(t, primary.Set("fallback", "foobaz"))
+
+	err = s.Delete("fallback")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"test": "foobar"}, primary.Snapshot())
+	assert.Empty(t, fallback.Snapshot())
+
+	err = s.Delete("fallback")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestChain_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.Chain(moneyloverkeychain.NewDirectoryStorage(t.TempDir()), moneyloverkeychain.NewEnvStorage())
+
+	assert.ErrorIs(t, s.Set("test", "foobar"), moneyloverkeychain.ErrReadOnly)
+	assert.ErrorIs(t, s.Delete("test"), moneyloverkeychain.ErrReadOnly)
+}
+
+func TestChainStorage_Backfill(t *testing.T) {
+	t.Parallel()
+
+	primary := moneyloverkeychain.NewMemoryStorage()
+	fallback := moneyloverkeychain.NewMemoryStorage()
+
+	require.NoError(t, fallback.Set("test", "foobar"))
+
+	s := moneyloverkeychain.NewChainStorage([]moneyloverkeychain.Storage{primary, fallback},
+		moneyloverkeychain.WithChainBackfill(),
+	)
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"test": "foobar"}, primary.Snapshot())
+}
+
+func TestChainStorage_ErrorPolicy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		policy         moneyloverkeychain.ChainErrorPolicy
+		mockPrimary    mock.StorageMocker
+		fallback       map[string]string
+		expectedResult string
+		expectedError  string
+	}{
+		{
+			scenario: "stop",
+			policy:   moneyloverkeychain.StopOnError,
+			mockPrimary: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Get", "test").Return("", errors.New("get error"))
+			}),
+			fallback:      map[string]string{"test": "foobar"},
+			expectedError: "get error",
+		},
+		{
+			scenario: "continue",
+			policy:   moneyloverkeychain.ContinueOnError,
+			mockPrimary: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Get", "test").Return("", errors.New("get error"))
+			}),
+			fallback:       map[string]string{"test": "foobar"},
+			expectedResult: "foobar",
+		},
+		{
+			scenario: "continue but not found",
+			policy:   moneyloverkeychain.ContinueOnError,
+			mockPrimary: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Get", "test").Return("", errors.New("get error"))
+			}),
+			fallback:      map[string]string{},
+			expectedError: "get error",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			fallback := moneyloverkeychain.NewMemoryStorage()
+			fallback.Restore(tc.fallback)
+
+			s := moneyloverkeychain.NewChainStorage([]moneyloverkeychain.Storage{tc.mockPrimary(t), fallback},
+				moneyloverkeychain.WithChainErrorPolicy(tc.policy),
+			)
+
+			data, err := s.Get("test")
+
+			assert.Equal(t, tc.expectedResult, data)
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestChainStorage_ErrorPolicy_Write(t *testing.T) {
+	t.Parallel()
+
+	newPrimary := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Set", "test", "foobar").Return(errors.New("set error"))
+		s.On("Delete", "test").Return(errors.New("delete error"))
+		s.On("Get", "test").Return("", errors.New("get error")).Maybe()
+	})
+
+	// Stop.
+	fallback := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewChainStorage([]moneyloverkeychain.Storage{newPrimary(t), fallback})
+
+	require.EqualError(t, s.Set("test", "foobar"), "set error")
+	require.EqualError(t, s.Delete("test"), "delete error")
+
+	assert.Empty(t, fallback.Snapshot())
+
+	// Continue.
+	s = moneyloverkeychain.NewChainStorage([]moneyloverkeychain.Storage{newPrimary(t), fallback},
+		moneyloverkeychain.WithChainErrorPolicy(moneyloverkeychain.ContinueOnError),
+	)
+
+	require.NoError(t, s.Set("test", "foobar"))
+
+	assert.Equal(t, map[string]string{"test": "foobar"}, fallback.Snapshot())
+
+	// The secret is deleted from the fallback, but it may still be in the primary storage.
+	require.EqualError(t, s.Delete("test"), "delete error")
+
+	assert.Empty(t, fallback.Snapshot())
+}
+
+func TestChainStorage_ErrorPolicy_Shadow(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		mockPrimary    mock.StorageMocker
+		expectedResult string
+		expectedError  string
+	}{
+		{
+			scenario: "stale password is deleted",
+			mockPrimary: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Set", "test", "foobaz").Return(errors.New("set error"))
+				s.On("Delete", "test").Return(nil)
+				s.On("Get", "test").Return("", moneyloverkeychain.ErrNotFound)
+			}),
+			expectedResult: "foobaz",
+		},
+		{
+			scenario: "stale password can not be deleted",
+			mockPrimary: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Set", "test", "foobaz").Return(errors.New("set error"))
+				s.On("Delete", "test").Return(errors.New("delete error"))
+				s.On("Get", "test").Return("foobar", nil)
+			}),
+			expectedResult: "foobar",
+			expectedError:  "set error",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			fallback := moneyloverkeychain.NewMemoryStorage()
+			s := moneyloverkeychain.NewChainStorage([]moneyloverkeychain.Storage{tc.mockPrimary(t), fallback},
+				moneyloverkeychain.WithChainErrorPolicy(moneyloverkeychain.ContinueOnError),
+			)
+
+			err := s.Set("test", "foobaz")
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+
+			assert.Equal(t, map[string]string{"test": "foobaz"}, fallback.Snapshot())
+
+			data, err := s.Get("test")
+
+			assert.Equal(t, tc.expectedResult, data)
+			require.NoError(t, err)
+		})
+	}
+}
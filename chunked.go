@@ -0,0 +1,294 @@
+package moneyloverkeychain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultChunkSize fits the smallest limit of the system keyrings, including the service and the key.
+	DefaultChunkSize = 2048
+
+	chunkManifestPrefix = "moneyloverkeychain/chunked:"
+)
+
+var (
+	_ Storage = (*chunkedStorage)(nil)
+	_ Lister  = (*chunkedStorage)(nil)
+)
+
+// chunkKeyPattern matches the keys of the chunks, see chunkKey.
+var chunkKeyPattern = regexp.MustCompile(`\.chunk-[0-9a-f]{16}-[0-9]+$`)
+
+// ErrCorruptValue indicates that the chunks of a value do not match its manifest.
+var ErrCorruptValue = errors.New("chunked value is corrupt")
+
+// ChunkedStorageOption configures the chunked storage.
+type ChunkedStorageOption func(s *chunkedStorage)
+
+// chunkManifest is stored in place of a value that is split into chunks.
+type chunkManifest struct {
+	Generation string `json:"generation"`
+	Chunks     int    `json:"chunks"`
+	Size       int    `json:"size"`
+	SHA256     string `json:"sha256"`
+}
+
+type chunkedStorage struct {
+	upstream  Storage
+	chunkSize int
+}
+
+// Set sets password for user. A password larger than the chunk size is split into chunks that are written before the
+// manifest, so the previous value stays readable until the manifest is replaced.
+func (s *chunkedStorage) Set(user, password string) error {
+	previous, err := s.manifest(user)
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrCorruptValue) {
+		return err
+	}
+
+	if len(password) <= s.chunkSize && !strings.HasPrefix(password, chunkManifestPrefix) {
+		if err := s.upstream.Set(user, password); err != nil {
+			return err
+		}
+	} else if err := s.setChunks(user, password); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		s.deleteChunks(user, *previous) // nolint: errcheck
+	}
+
+	return nil
+}
+
+func (s *chunkedStorage) setChunks(user, password string) error {
+	generation, err := newChunkGeneration()
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(password))
+	m := chunkManifest{
+		Generation: generation,
+		Size:       len(password),
+		SHA256:     hex.EncodeToString(sum[:]),
+	}
+
+	for _, chunk := range splitChunks(password, s.chunkSize) {
+		if err := s.upstream.Set(chunkKey(user, generation, m.Chunks), chunk); err != nil {
+			s.deleteChunks(user, m) // nolint: errcheck
+
+			return err
+		}
+
+		m.Chunks++
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := s.upstream.Set(user, chunkManifestPrefix+string(data)); err != nil {
+		s.deleteChunks(user, m) // nolint: errcheck
+
+		return err
+	}
+
+	return nil
+}
+
+// Get gets password and reassembles the chunks if it is split.
+func (s *chunkedStorage) Get(user string) (string, error) {
+	value, err := s.upstream.Get(user)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(value, chunkManifestPrefix) {
+		return value, nil
+	}
+
+	m, err := parseChunkManifest(value)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	sb.Grow(m.Size)
+
+	for i := 0; i < m.Chunks; i++ {
+		chunk, err := s.upstream.Get(chunkKey(user, m.Generation, i))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return "", fmt.Errorf("%w: chunk %d is missing", ErrCorruptValue, i)
+			}
+
+			return "", err
+		}
+
+		sb.WriteString(chunk)
+	}
+
+	password := sb.String()
+	sum := sha256.Sum256([]byte(password))
+
+	if len(password) != m.Size || hex.EncodeToString(sum[:]) != m.SHA256 {
+		return "", fmt.Errorf("%w: checksum mismatch", ErrCorruptValue)
+	}
+
+	return password, nil
+}
+
+// Delete deletes secret and all its chunks. The manifest is deleted first so that a failure never leaves a partial
+// value readable.
+func (s *chunkedStorage) Delete(user string) error {
+	m, err := s.manifest(user)
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrCorruptValue) {
+		return err
+	}
+
+	if err := s.upstream.Delete(user); err != nil {
+		return err
+	}
+
+	if m == nil {
+		return nil
+	}
+
+	return s.deleteChunks(user, *m)
+}
+
+// List lists the keys of the upstream storage that start with the prefix, without the chunks. It fails with
+// ErrListNotSupported if the upstream storage can not list its keys.
+func (s *chunkedStorage) List(prefix string) ([]string, error) {
+	l, ok := s.upstream.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+
+	keys, err := l.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(keys))
+
+	for _, k := range keys {
+		if !chunkKeyPattern.MatchString(k) {
+			result = append(result, k)
+		}
+	}
+
+	return result, nil
+}
+
+// manifest returns the manifest of the value, or nil if the value is not split.
+func (s *chunkedStorage) manifest(user string) (*chunkManifest, error) {
+	value, err := s.upstream.Get(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(value, chunkManifestPrefix) {
+		return nil, nil
+	}
+
+	return parseChunkManifest(value)
+}
+
+func (s *chunkedStorage) deleteChunks(user string, m chunkManifest) error {
+	var firstErr error
+
+	for i := 0; i < m.Chunks; i++ {
+		if err := s.upstream.Delete(chunkKey(user, m.Generation, i)); err != nil && !errors.Is(err, ErrNotFound) && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func parseChunkManifest(value string) (*chunkManifest, error) {
+	var m chunkManifest
+
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(value, chunkManifestPrefix)), &m); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorruptValue, err.Error())
+	}
+
+	return &m, nil
+}
+
+func newChunkGeneration() (string, error) {
+	b := make([]byte, 8)
+
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func chunkKey(user, generation string, i int) string {
+	return fmt.Sprintf("%s.chunk-%s-%d", user, generation, i)
+}
+
+// splitChunks splits the value into chunks of at most size bytes without breaking multi-byte characters.
+func splitChunks(value string, size int) []string {
+	chunks := make([]string, 0, len(value)/size+1)
+
+	for len(value) > size {
+		n := size
+
+		for n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+
+		if n == 0 {
+			n = size
+		}
+
+		chunks = append(chunks, value[:n])
+		value = value[n:]
+	}
+
+	return append(chunks, value)
+}
+
+// NewChunkedStorage creates a storage that splits the values larger than the chunk size into several entries of the
+// upstream storage, for the backends that limit the size of a value, such as the system keyring.
+//
+// The storage lists the keys of the upstream storage without the chunks. An index maintained by the upstream storage,
+// such as the one of WithKeyringIndex, still records every chunk, so a storage that can not list its keys is better
+// indexed outside the chunking, with NewIndexedStorage(NewChunkedStorage(upstream)).
+func NewChunkedStorage(upstream Storage, options ...ChunkedStorageOption) Storage {
+	s := &chunkedStorage{
+		upstream:  upstream,
+		chunkSize: DefaultChunkSize,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithChunkSize sets the maximum size of a chunk in bytes.
+func WithChunkSize(size int) ChunkedStorageOption {
+	return func(s *chunkedStorage) {
+		if size > 0 {
+			s.chunkSize = size
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package moneyloverkeychain_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+// failingStorage fails the writes after a number of successful writes.
+type failingStorage struct {
+	*moneyloverkeychain.MemoryStorage
+
+	writes int
+}
+
+func (s *failingStorage) Set(user, password string) error {
+	if s.writes == 0 {
+		return errors.New("set error")
+	}
+
+	s.writes--
+
+	return s.MemoryStorage.Set(user, password)
+}
+
+func TestChunkedStorage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		password       string
+		expectedValues int
+	}{
+		{
+			scenario:       "small",
+			password:       "foobar",
+			expectedValues: 1,
+		},
+		{
+			scenario:       "exact",
+			password:       strings.Repeat("a", 10),
+			expectedValues: 1,
+		},
+		{
+			scenario:       "large",
+			password:       strings.Repeat("a", 25),
+			expectedValues: 4,
+		},
+		{
+			scenario:       "multi-byte characters",
+			password:       strings.Repeat("ế", 7),
+			expectedValues: 4,
+		},
+		{
+			scenario:       "looks like a manifest",
+			password:       `moneyloverkeychain/chunked:{}`,
+			expectedValues: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			upstream := moneyloverkeychain.NewMemoryStorage()
+			s := moneyloverkeychain.NewChunkedStorage(upstream, moneyloverkeychain.WithChunkSize(10))
+
+			// Get not found.
+			data, err := s.Get("test")
+
+			assert.Empty(t, data)
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+			// Set.
+			err = s.Set("test", tc.password)
+			require.NoError(t, err)
+
+			data, err = s.Get("test")
+
+			assert.Equal(t, tc.password, data)
+			require.NoError(t, err)
+
+			snapshot := upstream.Snapshot()
+
+			assert.Len(t, snapshot, tc.expectedValues)
+
+			for k, v := range snapshot {
+				if k != "test" {
+					assert.LessOrEqual(t, len(v), 10)
+					assert.True(t, utf8.ValidString(v))
+				}
+			}
+
+			// Overwrite.
+			err = s.Set("test", "foobaz")
+			require.NoError(t, err)
+
+			assert.Equal(t, map[string]string{"test": "foobaz"}, upstream.Snapshot())
+
+			err = s.Set("test", tc.password)
+			require.NoError(t, err)
+
+			// Delete.
+			err = s.Delete("test")
+			require.NoError(t, err)
+
+			assert.Empty(t, upstream.Snapshot())
+
+			data, err = s.Get("test")
+
+			assert.Empty(t, data)
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+			err = s.Delete("test")
+
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+		})
+	}
+}
+
+func TestChunkedStorage_PartialWrite(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		writes   int
+	}{
+		{
+			scenario: "chunk fails",
+			writes:   1,
+		},
+		{
+			scenario: "manifest fails",
+			writes:   3,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			upstream := &failingStorage{MemoryStorage: moneyloverkeychain.NewMemoryStorage(), writes: 4}
+			s := moneyloverkeychain.NewChunkedStorage(upstream, moneyloverkeychain.WithChunkSize(10))
+			previous := strings.Repeat("a", 25)
+
+			require.NoError(t, s.Set("test", previous))
+
+			snapshot := upstream.Snapshot()
+			upstream.writes = tc.writes
+
+			err := s.Set("test", strings.Repeat("b", 25))
+			require.EqualError(t, err, "set error")
+
+			// The previous value is intact and the new chunks are cleaned up.
+			data, err := s.Get("test")
+
+			assert.Equal(t, previous, data)
+			require.NoError(t, err)
+
+			assert.Equal(t, snapshot, upstream.Snapshot())
+		})
+	}
+}
+
+func TestChunkedStorage_Corrupt(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewChunkedStorage(upstream, moneyloverkeychain.WithChunkSize(10))
+
+	require.NoError(t, s.Set("test", strings.Repeat("a", 25)))
+
+	snapshot := upstream.Snapshot()
+
+	var chunks []string
+
+	for k := range snapshot {
+		if k != "test" {
+			chunks = append(chunks, k)
+		}
+	}
+
+	// Tampered chunk.
+	require.NoError(t, upstream.Set(chunks[0], "bbbbbbbbbb"))
+
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	require.EqualError(t, err, "chunked value is corrupt: checksum mismatch")
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrCorruptValue)
+
+	// Missing chunk.
+	require.NoError(t, upstream.Delete(chunks[0]))
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrCorruptValue)
+
+	// A corrupt value can still be deleted.
+	require.NoError(t, s.Delete("test"))
+
+	assert.Empty(t, upstream.Snapshot())
+}
+
+func TestChunkedStorage_List(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewChunkedStorage(upstream, moneyloverkeychain.WithChunkSize(10))
+
+	require.NoError(t, s.Set("large", strings.Repeat("a", 25)))
+	require.NoError(t, s.Set("small", "foobar"))
+
+	require.Len(t, upstream.Snapshot(), 5)
+
+	keys, err := s.(moneyloverkeychain.Lister).List("")
+
+	assert.Equal(t, []string{"large", "small"}, keys)
+	require.NoError(t, err)
+
+	// The upstream storage can not list its keys.
+	keys, err = moneyloverkeychain.NewChunkedStorage(mock.MockStorage()(t)).(moneyloverkeychain.Lister).List("")
+
+	assert.Empty(t, keys)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrListNotSupported)
+}
+
+func TestChunkedStorage_Indexed(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewIndexedStorage(
+		moneyloverkeychain.NewChunkedStorage(upstream, moneyloverkeychain.WithChunkSize(10)),
+	)
+
+	require.NoError(t, s.Set("large", strings.Repeat("a", 25)))
+
+	// The index is outside the chunking, so it does not record the chunks.
+	keys, err := s.List("")
+
+	assert.Equal(t, []string{"large"}, keys)
+	require.NoError(t, err)
+
+	data, err := s.Get("large")
+
+	assert.Equal(t, strings.Repeat("a", 25), data)
+	require.NoError(t, err)
+}
@@ -0,0 +1,124 @@
+package moneyloverkeychain
+
+import "context"
+
+var (
+	_ ContextStorage = (*contextStorage)(nil)
+	_ Storage        = (*noContextStorage)(nil)
+)
+
+// ContextStorage is a keychain storage that honours the deadline and the cancellation of the context.
+type ContextStorage interface {
+	// Set sets password in keychain for user.
+	Set(ctx context.Context, user, password string) error
+	// Get gets password from keychain.
+	Get(ctx context.Context, user string) (string, error)
+	// Delete deletes secret from keychain.
+	Delete(ctx context.Context, user string) error
+}
+
+type contextStorage struct {
+	upstream Storage
+}
+
+// Set sets password in the upstream storage for user, or returns the error of the context if it is done first.
+func (s *contextStorage) Set(ctx context.Context, user, password string) error {
+	_, err := s.do(ctx, func() (string, error) {
+		return "", s.upstream.Set(user, password)
+	})
+
+	return err
+}
+
+// Get gets password from the upstream storage, or returns the error of the context if it is done first.
+func (s *contextStorage) Get(ctx context.Context, user string) (string, error) {
+	return s.do(ctx, func() (string, error) {
+		return s.upstream.Get(user)
+	})
+}
+
+// Delete deletes secret from the upstream storage, or returns the error of the context if it is done first.
+func (s *contextStorage) Delete(ctx context.Context, user string) error {
+	_, err := s.do(ctx, func() (string, error) {
+		return "", s.upstream.Delete(user)
+	})
+
+	return err
+}
+
+func (s *contextStorage) do(ctx context.Context, fn func() (string, error)) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	// The context can never be done.
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		value string
+		err   error
+	}
+
+	// The channel is buffered so the goroutine does not leak if the context is done first.
+	ch := make(chan result, 1)
+
+	go func() {
+		value, err := fn()
+
+		ch <- result{value: value, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.value, r.err
+
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+type noContextStorage struct {
+	upstream ContextStorage
+}
+
+// Set sets password in the upstream storage for user.
+func (s *noContextStorage) Set(user, password string) error {
+	return s.upstream.Set(context.Background(), user, password)
+}
+
+// Get gets password from the upstream storage.
+func (s *noContextStorage) Get(user string) (string, error) {
+	return s.upstream.Get(context.Background(), user)
+}
+
+// Delete deletes secret from the upstream storage.
+func (s *noContextStorage) Delete(user string) error {
+	return s.upstream.Delete(context.Background(), user)
+}
+
+// ToContextStorage adapts a Storage to a ContextStorage. The operations of the storage, such as the system keyring
+// calls, can not be interrupted, so they keep running in the background when the context is done first, and a Set or a
+// Delete may still take effect after the context error is returned.
+func ToContextStorage(s Storage) ContextStorage {
+	if n, ok := s.(*noContextStorage); ok {
+		return n.upstream
+	}
+
+	return &contextStorage{upstream: s}
+}
+
+// FromContextStorage adapts a ContextStorage to a Storage, using context.Background() for the operations.
+func FromContextStorage(s ContextStorage) Storage {
+	if c, ok := s.(*contextStorage); ok {
+		return c.upstream
+	}
+
+	return &noContextStorage{upstream: s}
+}
+
+// NewContextStorage creates a keychain storage that honours the deadline and the cancellation of the context.
+func NewContextStorage(service string, options ...StorageOption) ContextStorage {
+	return ToContextStorage(NewStorage(service, options...))
+}
@@ -0,0 +1,143 @@
+package moneyloverkeychain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+// blockingStorage blocks the operations until it is released.
+type blockingStorage struct {
+	*moneyloverkeychain.MemoryStorage
+
+	release chan struct{}
+}
+
+func (s *blockingStorage) Set(user, password string) error {
+	<-s.release
+
+	return s.MemoryStorage.Set(user, password)
+}
+
+func (s *blockingStorage) Get(user string) (string, error) {
+	<-s.release
+
+	return s.MemoryStorage.Get(user)
+}
+
+func (s *blockingStorage) Delete(user string) error {
+	<-s.release
+
+	return s.MemoryStorage.Delete(user)
+}
+
+func TestToContextStorage(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.ToContextStorage(moneyloverkeychain.NewMemoryStorage())
+	ctx := context.Background()
+
+	// Get not found.
+	data, err := s.Get(ctx, "test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set(ctx, "test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get(ctx, "test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete(ctx, "test")
+	require.NoError(t, err)
+
+	err = s.Delete(ctx, "test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestToContextStorage_Deadline(t *testing.T) {
+	t.Parallel()
+
+	upstream := &blockingStorage{MemoryStorage: moneyloverkeychain.NewMemoryStorage(), release: make(chan struct{})}
+	s := moneyloverkeychain.ToContextStorage(upstream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	data, err := s.Get(ctx, "test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.ErrorIs(t, s.Set(ctx, "test", "foobar"), context.DeadlineExceeded)
+	assert.ErrorIs(t, s.Delete(ctx, "test"), context.DeadlineExceeded)
+
+	close(upstream.release)
+
+	// A canceled context fails right away.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, s.Set(ctx, "test", "foobar"), context.Canceled)
+
+	// The operation completes when it is faster than the deadline.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, s.Set(ctx, "test", "foobar"))
+
+	data, err = s.Get(ctx, "test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+}
+
+func TestFromContextStorage(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.FromContextStorage(moneyloverkeychain.ToContextStorage(upstream))
+
+	// The adapters unwrap each other.
+	assert.Same(t, upstream, s)
+
+	s = moneyloverkeychain.FromContextStorage(contextOnlyStorage{upstream: upstream})
+
+	require.NoError(t, s.Set("test", "foobar"))
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Delete("test"))
+
+	assert.Empty(t, upstream.Snapshot())
+}
+
+type contextOnlyStorage struct {
+	upstream moneyloverkeychain.Storage
+}
+
+func (s contextOnlyStorage) Set(_ context.Context, user, password string) error {
+	return s.upstream.Set(user, password)
+}
+
+func (s contextOnlyStorage) Get(_ context.Context, user string) (string, error) {
+	return s.upstream.Get(user)
+}
+
+func (s contextOnlyStorage) Delete(_ context.Context, user string) error {
+	return s.upstream.Delete(user)
+}
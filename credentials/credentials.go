@@ -51,22 +51,29 @@ func (c *Credentials) load() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.loaded = true
+	c.loaded = false
 	c.username = ""
 	c.password = ""
 
 	data, err := c.storage.Get(c.key)
 	if err != nil {
+		// The credentials are not cached as empty on error, so the next call tries again.
 		if !errors.Is(err, keyring.ErrNotFound) {
 			c.logger.Error(context.Background(), "could not get credentials", "error", err)
+
+			return
 		}
 
+		c.loaded = true
+
 		return
 	}
 
 	var t credentials
 
 	if err := json.Unmarshal([]byte(data), &t); err != nil {
+		c.loaded = true
+
 		c.logger.Error(context.Background(), "could not unmarshal credentials", "error", err)
 
 		return
@@ -155,6 +162,33 @@ func New(deviceID uuid.UUID, options ...Option) *Credentials {
 	return c
 }
 
+// DeviceIDs lists the device IDs that have credentials in keychain, such as the keychain storage with WithKeyringIndex.
+// The keys that are not device IDs are skipped. It fails with moneyloverkeychain.ErrListNotSupported if the storage can
+// not list its keys, such as the default keychain storage.
+func DeviceIDs(options ...Option) ([]uuid.UUID, error) {
+	c := New(uuid.Nil, options...)
+
+	l, ok := c.storage.(moneyloverkeychain.Lister)
+	if !ok {
+		return nil, moneyloverkeychain.ErrListNotSupported
+	}
+
+	keys, err := l.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(keys))
+
+	for _, k := range keys {
+		if id, err := uuid.Parse(k); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
 // WithStorage sets storage for Credentials.
 func WithStorage(storage moneyloverkeychain.Storage) Option {
 	return func(p *Credentials) {
@@ -162,6 +196,14 @@ func WithStorage(storage moneyloverkeychain.Storage) Option {
 	}
 }
 
+// WithKeyringIndex sets the keychain storage with an index of its keys for Credentials, so that the device IDs written
+// with it are listed by DeviceIDs. See moneyloverkeychain.WithKeyringIndex.
+func WithKeyringIndex(options ...moneyloverkeychain.IndexedStorageOption) Option {
+	return func(p *Credentials) {
+		p.storage = moneyloverkeychain.NewStorage(credentialsService, moneyloverkeychain.WithKeyringIndex(options...))
+	}
+}
+
 // WithLogger sets logger for Credentials.
 func WithLogger(logger ctxd.Logger) Option {
 	return func(p *Credentials) {
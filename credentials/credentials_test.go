@@ -159,6 +159,29 @@ func TestCredentials_LoadOnce(t *testing.T) {
 	assert.Equal(t, expectedPassword, c.Password())
 }
 
+func TestCredentials_LoadAgainOnError(t *testing.T) {
+	deviceID := uuid.New()
+
+	storage := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Get", deviceID.String()).
+			Return("", errors.New("get error")).
+			Once()
+
+		s.On("Get", deviceID.String()).
+			Return(`{"username":"user@example.org","password":"123456"}`, nil).
+			Once()
+	})(t)
+
+	c := New(deviceID, WithStorage(storage))
+
+	// 1st run fails.
+	assert.Empty(t, c.Username())
+
+	// 2nd run calls storage again.
+	assert.Equal(t, "user@example.org", c.Username())
+	assert.Equal(t, "123456", c.Password())
+}
+
 func TestCredentials_LoadKeyring(t *testing.T) {
 	deviceID := uuid.New()
 
@@ -402,3 +425,82 @@ func TestCredentials_DeleteKeyring(t *testing.T) {
 		assert.Equal(t, keyring.ErrNotFound, err)
 	})
 }
+
+func TestCredentials_MemoryStorage(t *testing.T) {
+	t.Parallel()
+
+	deviceID := uuid.New()
+
+	s := moneyloverkeychain.NewMemoryStorage()
+	s.Restore(map[string]string{
+		deviceID.String(): `{"username":"user@example.org","password":"123456"}`,
+	})
+
+	c := New(deviceID, WithStorage(s))
+
+	assert.Equal(t, "user@example.org", c.Username())
+	assert.Equal(t, "123456", c.Password())
+
+	// Update.
+	err := c.Update("john@example.org", "654321")
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		deviceID.String(): `{"username":"john@example.org","password":"654321"}`,
+	}
+
+	assert.Equal(t, expected, s.Snapshot())
+
+	// Delete.
+	err = c.Delete()
+	require.NoError(t, err)
+
+	assert.Empty(t, s.Snapshot())
+	assert.Empty(t, New(deviceID, WithStorage(s)).Username())
+}
+
+func TestDeviceIDs(t *testing.T) {
+	t.Parallel()
+
+	deviceID := uuid.MustParse("5e0d76a4-e1a0-4d2b-a1d6-4b2c7a3f2a10")
+
+	s := moneyloverkeychain.NewMemoryStorage()
+	s.Restore(map[string]string{
+		deviceID.String(): `{"username":"user@example.org","password":"123456"}`,
+		"not a device id": "foobar",
+	})
+
+	ids, err := DeviceIDs(WithStorage(s))
+
+	assert.Equal(t, []uuid.UUID{deviceID}, ids)
+	require.NoError(t, err)
+
+	// The storage can not list its keys.
+	ids, err = DeviceIDs(WithStorage(mock.MockStorage()(t)))
+
+	assert.Empty(t, ids)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrListNotSupported)
+}
+
+func TestDeviceIDsKeyring(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	deviceID := uuid.New()
+
+	test.Run(t, credentialsService, deviceID.String(), nil, func(t *testing.T) { //nolint: thelper
+		err := New(deviceID, WithKeyringIndex()).Update("user@example.org", "123456")
+		require.NoError(t, err)
+
+		ids, err := DeviceIDs(WithKeyringIndex())
+
+		assert.Contains(t, ids, deviceID)
+		require.NoError(t, err)
+
+		require.NoError(t, New(deviceID, WithKeyringIndex()).Delete())
+
+		ids, err = DeviceIDs(WithKeyringIndex())
+
+		assert.NotContains(t, ids, deviceID)
+		require.NoError(t, err)
+	})
+}
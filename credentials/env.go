@@ -0,0 +1,63 @@
+package credentials
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/nhatthm/moneyloverapi"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+const (
+	// EnvUsername is the environment variable of the username.
+	EnvUsername = "MONEYLOVER_USERNAME"
+	// EnvPassword is the environment variable of the password.
+	EnvPassword = "MONEYLOVER_PASSWORD"
+)
+
+var _ moneyloverkeychain.Storage = (*envStorage)(nil)
+
+// envStorage provides the credentials of any key from the environment variables.
+type envStorage struct{}
+
+func (envStorage) Set(string, string) error {
+	return moneyloverkeychain.ErrReadOnly
+}
+
+func (envStorage) Get(string) (string, error) {
+	username, hasUsername := os.LookupEnv(EnvUsername)
+	password, hasPassword := os.LookupEnv(EnvPassword)
+
+	if !hasUsername && !hasPassword {
+		return "", moneyloverkeychain.ErrNotFound
+	}
+
+	data, err := json.Marshal(credentials{
+		Username: username,
+		Password: password,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (envStorage) Delete(string) error {
+	return moneyloverkeychain.ErrReadOnly
+}
+
+// NewFromEnv initiates a new Credentials which reads the username and password from the MONEYLOVER_USERNAME and
+// MONEYLOVER_PASSWORD environment variables. The credentials are read-only.
+func NewFromEnv(options ...Option) *Credentials {
+	return New(uuid.Nil, append([]Option{WithStorage(envStorage{})}, options...)...)
+}
+
+// WithEnvCredentialsProvider sets the environment variables as a credential provider.
+func WithEnvCredentialsProvider(options ...Option) moneyloverapi.Option {
+	return func(c *moneyloverapi.Client) {
+		moneyloverapi.WithCredentialsProvider(NewFromEnv(options...))(c)
+	}
+}
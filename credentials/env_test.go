@@ -0,0 +1,39 @@
+package credentials
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv(EnvUsername, "user@example.org")
+	t.Setenv(EnvPassword, "123456")
+
+	c := NewFromEnv()
+
+	assert.Equal(t, "user@example.org", c.Username())
+	assert.Equal(t, "123456", c.Password())
+
+	assert.ErrorIs(t, c.Update("john@example.org", "654321"), moneyloverkeychain.ErrReadOnly)
+	assert.ErrorIs(t, c.Delete(), moneyloverkeychain.ErrReadOnly)
+
+	assert.Equal(t, "user@example.org", c.Username())
+}
+
+func TestNewFromEnv_Missing(t *testing.T) {
+	t.Setenv(EnvUsername, "")
+	t.Setenv(EnvPassword, "")
+
+	require.NoError(t, os.Unsetenv(EnvUsername))
+	require.NoError(t, os.Unsetenv(EnvPassword))
+
+	c := NewFromEnv()
+
+	assert.Empty(t, c.Username())
+	assert.Empty(t, c.Password())
+}
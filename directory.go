@@ -0,0 +1,99 @@
+package moneyloverkeychain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var _ Storage = (*directoryStorage)(nil)
+
+// ErrInvalidKey indicates that a key can not be mapped to a secret.
+var ErrInvalidKey = errors.New("invalid key")
+
+// DirectoryStorageOption configures the directory storage.
+type DirectoryStorageOption func(s *directoryStorage)
+
+type directoryStorage struct {
+	dir      string
+	fileName func(key string) string
+}
+
+// Set returns ErrReadOnly.
+func (s *directoryStorage) Set(string, string) error {
+	return ErrReadOnly
+}
+
+// Get gets password from the file of the user.
+func (s *directoryStorage) Get(user string) (string, error) {
+	name := s.fileName(user)
+
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, user)
+	}
+
+	// Without a directory, the secrets would be read from the working directory.
+	if s.dir == "" {
+		return "", ErrNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, name)) // nolint: gosec
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrNotFound
+		}
+
+		return "", err
+	}
+
+	secret := strings.TrimSuffix(string(data), "\n")
+
+	return strings.TrimSuffix(secret, "\r"), nil
+}
+
+// Delete returns ErrReadOnly.
+func (s *directoryStorage) Delete(string) error {
+	return ErrReadOnly
+}
+
+// NewDirectoryStorage creates a read-only storage that reads the secret of a key from the file of the same name in a
+// directory, such as /run/secrets or a mounted Kubernetes secret volume. A trailing new line is removed from the secret.
+//
+// If the directory is empty, no secret is found.
+func NewDirectoryStorage(dir string, options ...DirectoryStorageOption) Storage {
+	s := &directoryStorage{
+		dir: dir,
+		fileName: func(key string) string {
+			return key
+		},
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// NewSystemdCredentialsStorage creates a read-only storage in the directory of the systemd credentials, which is
+// $CREDENTIALS_DIRECTORY. If the variable is not set, for example outside a systemd unit, no secret is found.
+func NewSystemdCredentialsStorage(options ...DirectoryStorageOption) Storage {
+	return NewDirectoryStorage(os.Getenv("CREDENTIALS_DIRECTORY"), options...)
+}
+
+// WithFileNamePrefix prefixes the file names of the keys, for example with the service, so that several storages can
+// share the same directory.
+func WithFileNamePrefix(prefix string) DirectoryStorageOption {
+	return WithFileName(func(key string) string {
+		return prefix + key
+	})
+}
+
+// WithFileName sets the mapping from a key to its file name.
+func WithFileName(fileName func(key string) string) DirectoryStorageOption {
+	return func(s *directoryStorage) {
+		s.fileName = fileName
+	}
+}
@@ -0,0 +1,134 @@
+package moneyloverkeychain_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+func TestDirectoryStorage_Get(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("foobar\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "crlf"), []byte("foobar\r\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "raw"), []byte("foo\nbar"), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o700))
+
+	testCases := []struct {
+		scenario       string
+		key            string
+		expectedResult string
+		expectedError  string
+	}{
+		{
+			scenario:      "not found",
+			key:           "unknown",
+			expectedError: "secret not found in keyring",
+		},
+		{
+			scenario:       "trailing new line",
+			key:            "token",
+			expectedResult: "foobar",
+		},
+		{
+			scenario:       "trailing crlf",
+			key:            "crlf",
+			expectedResult: "foobar",
+		},
+		{
+			scenario:       "multiple lines",
+			key:            "raw",
+			expectedResult: "foo\nbar",
+		},
+		{
+			scenario:      "path traversal",
+			key:           "../token",
+			expectedError: `invalid key: "../token"`,
+		},
+		{
+			scenario:      "directory",
+			key:           "sub",
+			expectedError: "read " + filepath.Join(dir, "sub") + ": is a directory",
+		},
+	}
+
+	s := moneyloverkeychain.NewDirectoryStorage(dir)
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := s.Get(tc.key)
+
+			assert.Equal(t, tc.expectedResult, data)
+
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestDirectoryStorage_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewDirectoryStorage(t.TempDir())
+
+	assert.ErrorIs(t, s.Set("foo", "bar"), moneyloverkeychain.ErrReadOnly)
+	assert.ErrorIs(t, s.Delete("foo"), moneyloverkeychain.ErrReadOnly)
+}
+
+func TestDirectoryStorage_FileName(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "MONEYLOVER_TOKEN"), []byte("foobar"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "moneyloverapi.token.test"), []byte("foobaz"), 0o600))
+
+	data, err := moneyloverkeychain.NewDirectoryStorage(dir, moneyloverkeychain.WithFileName(strings.ToUpper)).
+		Get("moneylover_token")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	data, err = moneyloverkeychain.NewDirectoryStorage(dir, moneyloverkeychain.WithFileNamePrefix("moneyloverapi.token.")).
+		Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+}
+
+func TestSystemdCredentialsStorage(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("foobar"), 0o600))
+
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	data, err := moneyloverkeychain.NewSystemdCredentialsStorage().Get("token")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+}
+
+func TestSystemdCredentialsStorage_NoDirectory(t *testing.T) {
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+
+	// The working directory of the test has a go.mod, it must not be read.
+	data, err := moneyloverkeychain.NewSystemdCredentialsStorage().Get("go.mod")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
@@ -0,0 +1,2 @@
+// Package dockercred provides a storage that delegates to a docker credential helper.
+package dockercred
@@ -0,0 +1,180 @@
+package dockercred
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+const helperPrefix = "docker-credential-"
+
+// errCredentialsNotFound is the message of the helpers when the credentials are not found, see
+// https://github.com/docker/docker-credential-helpers/blob/master/credentials/error.go
+const errCredentialsNotFound = "credentials not found in native keychain"
+
+var (
+	_ moneyloverkeychain.Storage = (*Storage)(nil)
+	_ moneyloverkeychain.Lister  = (*Storage)(nil)
+)
+
+// Option configures Storage.
+type Option func(s *Storage)
+
+// credentials is the payload of the helper protocol.
+type credentials struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// Storage is a storage that delegates to an executable speaking the docker credential helper protocol. The secret of a
+// key is stored with the key as the username, and the service and the key as the server URL.
+type Storage struct {
+	helper string
+	prefix string
+	env    []string
+}
+
+// Set sets password in the helper for user.
+func (s *Storage) Set(user, password string) error {
+	payload, err := json.Marshal(credentials{
+		ServerURL: s.serverURL(user),
+		Username:  user,
+		Secret:    password,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.run("store", payload)
+
+	return err
+}
+
+// Get gets password from the helper.
+func (s *Storage) Get(user string) (string, error) {
+	out, err := s.run("get", []byte(s.serverURL(user)))
+	if err != nil {
+		return "", err
+	}
+
+	var c credentials
+
+	if err := json.Unmarshal(out, &c); err != nil {
+		return "", fmt.Errorf("could not unmarshal credentials: %w", err)
+	}
+
+	return c.Secret, nil
+}
+
+// Delete deletes secret from the helper.
+func (s *Storage) Delete(user string) error {
+	_, err := s.run("erase", []byte(s.serverURL(user)))
+
+	return err
+}
+
+// List lists the keys of the service that start with the prefix.
+func (s *Storage) List(prefix string) ([]string, error) {
+	out, err := s.run("list", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers map[string]string
+
+	if err := json.Unmarshal(out, &servers); err != nil {
+		return nil, fmt.Errorf("could not unmarshal credentials: %w", err)
+	}
+
+	keys := make([]string, 0, len(servers))
+
+	for serverURL := range servers {
+		if strings.HasPrefix(serverURL, s.prefix+prefix) {
+			keys = append(keys, strings.TrimPrefix(serverURL, s.prefix))
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (s *Storage) serverURL(user string) string {
+	return s.prefix + user
+}
+
+func (s *Storage) run(action string, input []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(s.helper, action) // nolint: gosec
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if s.env != nil {
+		cmd.Env = s.env
+	}
+
+	if err := cmd.Run(); err != nil {
+		// The helpers print the error to stdout.
+		msg := strings.TrimSpace(stdout.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stderr.String())
+		}
+
+		if msg == errCredentialsNotFound {
+			return nil, moneyloverkeychain.ErrNotFound
+		}
+
+		var exitErr *exec.ExitError
+
+		if msg != "" && errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%s %s: %s", filepath.Base(s.helper), action, msg)
+		}
+
+		return nil, fmt.Errorf("%s %s: %w", filepath.Base(s.helper), action, err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// New creates a storage that delegates to a docker credential helper. The helper is either a path to an executable or a
+// name such as "pass" or "osxkeychain", which is looked up as docker-credential-<name> in the PATH.
+func New(helper, service string, options ...Option) *Storage {
+	if !strings.ContainsRune(helper, filepath.Separator) && !strings.HasPrefix(helper, helperPrefix) {
+		helper = helperPrefix + helper
+	}
+
+	s := &Storage{
+		helper: helper,
+		prefix: service + "/",
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithServerURLPrefix sets the prefix of the server URLs, which is "<service>/" by default.
+func WithServerURLPrefix(prefix string) Option {
+	return func(s *Storage) {
+		s.prefix = prefix
+	}
+}
+
+// WithEnv sets the environment of the helper. By default, the helper inherits the environment of the current process.
+func WithEnv(env []string) Option {
+	return func(s *Storage) {
+		s.env = env
+	}
+}
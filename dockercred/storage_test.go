@@ -0,0 +1,195 @@
+package dockercred_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/dockercred"
+)
+
+const envHelperStore = "DOCKERCRED_TEST_HELPER_STORE"
+
+type fakeCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// TestMain runs the test binary as a fake credential helper that stores the credentials in a json file.
+func TestMain(m *testing.M) {
+	if path := os.Getenv(envHelperStore); path != "" {
+		if err := runHelper(path, os.Args[len(os.Args)-1]); err != nil {
+			fmt.Println(err.Error()) // nolint: forbidigo
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func runHelper(path, action string) error {
+	store := map[string]fakeCredentials{}
+
+	if data, err := os.ReadFile(filepath.Clean(path)); err == nil {
+		if err := json.Unmarshal(data, &store); err != nil {
+			return err
+		}
+	}
+
+	input, err := io.ReadAll(bufio.NewReader(os.Stdin))
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "store":
+		var c fakeCredentials
+
+		if err := json.Unmarshal(input, &c); err != nil {
+			return err
+		}
+
+		store[c.ServerURL] = c
+
+	case "get":
+		c, ok := store[string(input)]
+		if !ok {
+			return errors.New("credentials not found in native keychain")
+		}
+
+		return json.NewEncoder(os.Stdout).Encode(c)
+
+	case "erase":
+		if _, ok := store[string(input)]; !ok {
+			return errors.New("credentials not found in native keychain")
+		}
+
+		delete(store, string(input))
+
+	case "list":
+		servers := map[string]string{}
+
+		for k, c := range store {
+			servers[k] = c.Username
+		}
+
+		return json.NewEncoder(os.Stdout).Encode(servers)
+
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+
+	data, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+func newStorage(t *testing.T, service string) (*dockercred.Storage, string) {
+	t.Helper()
+
+	store := filepath.Join(t.TempDir(), "store.json")
+	env := append(os.Environ(), envHelperStore+"="+store)
+
+	return dockercred.New(os.Args[0], service, dockercred.WithEnv(env)), store
+}
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	s, store := newStorage(t, "moneyloverapi.token")
+
+	// Get not found.
+	data, err := s.Get("user@example.org")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("user@example.org", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("user@example.org")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Clean(store))
+	require.NoError(t, err)
+
+	expected := `{"moneyloverapi.token/user@example.org":{"ServerURL":"moneyloverapi.token/user@example.org","Username":"user@example.org","Secret":"foobar"}}`
+
+	assert.Equal(t, expected, string(content))
+
+	// Delete.
+	err = s.Delete("user@example.org")
+	require.NoError(t, err)
+
+	data, err = s.Get("user@example.org")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("user@example.org")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestStorage_List(t *testing.T) {
+	t.Parallel()
+
+	s, store := newStorage(t, "moneyloverapi.token")
+
+	require.NoError(t, s.Set("user@example.org", "foobar"))
+	require.NoError(t, s.Set("john@example.org", "foobar"))
+
+	other := dockercred.New(os.Args[0], "other", dockercred.WithEnv(append(os.Environ(), envHelperStore+"="+store)))
+
+	require.NoError(t, other.Set("user@example.org", "foobar"))
+
+	keys, err := s.List("")
+
+	assert.Equal(t, []string{"john@example.org", "user@example.org"}, keys)
+	require.NoError(t, err)
+
+	keys, err = s.List("user")
+
+	assert.Equal(t, []string{"user@example.org"}, keys)
+	require.NoError(t, err)
+}
+
+func TestStorage_HelperError(t *testing.T) {
+	t.Parallel()
+
+	s, store := newStorage(t, "moneyloverapi.token")
+
+	require.NoError(t, os.WriteFile(store, []byte("{"), 0o600))
+
+	_, err := s.Get("user@example.org")
+
+	require.EqualError(t, err, filepath.Base(os.Args[0])+" get: unexpected end of JSON input")
+}
+
+func TestStorage_HelperNotFound(t *testing.T) {
+	t.Parallel()
+
+	s := dockercred.New("moneyloverkeychain-unknown", "moneyloverapi.token")
+
+	_, err := s.Get("user@example.org")
+
+	require.EqualError(t, err, `docker-credential-moneyloverkeychain-unknown get: exec: "docker-credential-moneyloverkeychain-unknown": executable file not found in $PATH`)
+}
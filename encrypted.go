@@ -0,0 +1,250 @@
+package moneyloverkeychain
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nhatthm/moneyloverkeychain/internal/seal"
+)
+
+const encryptedValuePrefix = "moneyloverkeychain/encrypted:"
+
+// Cipher is an AEAD algorithm for sealing the secrets.
+type Cipher string
+
+const (
+	// AES256GCM is AES-256 in Galois/Counter Mode.
+	AES256GCM Cipher = "aes-256-gcm"
+	// XChaCha20Poly1305 is XChaCha20-Poly1305 with an extended nonce.
+	XChaCha20Poly1305 Cipher = "xchacha20-poly1305"
+)
+
+var _ Storage = (*EncryptedStorage)(nil)
+
+var (
+	// ErrUnknownEncryptionKey indicates that a secret is sealed with a key that is not configured.
+	ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+	// ErrNotEncrypted indicates that a secret is not sealed by an encrypted storage.
+	ErrNotEncrypted = errors.New("secret is not encrypted")
+	// ErrUnsupportedCipher indicates that a cipher is not supported.
+	ErrUnsupportedCipher = errors.New("unsupported cipher")
+)
+
+// EncryptionKey is an encryption key identified by an ID that is stored with every secret it seals.
+type EncryptionKey struct {
+	ID  string
+	Key KeySource
+}
+
+// EncryptedStorageOption configures EncryptedStorage.
+type EncryptedStorageOption func(s *EncryptedStorage)
+
+// EncryptedStorage is a storage that seals the secrets before writing them to the upstream storage.
+type EncryptedStorage struct {
+	upstream  Storage
+	cipher    Cipher
+	primary   string
+	keys      map[string]KeySource
+	plaintext bool
+}
+
+// Set seals password with the primary key and sets it in the upstream storage for user.
+func (s *EncryptedStorage) Set(user, password string) error {
+	value, err := s.seal(user, password)
+	if err != nil {
+		return err
+	}
+
+	return s.upstream.Set(user, value)
+}
+
+// Get gets the sealed password from the upstream storage and opens it with the key it was sealed with.
+func (s *EncryptedStorage) Get(user string) (string, error) {
+	value, err := s.upstream.Get(user)
+	if err != nil {
+		return "", err
+	}
+
+	password, _, err := s.open(user, value)
+
+	return password, err
+}
+
+// Delete deletes secret from the upstream storage.
+func (s *EncryptedStorage) Delete(user string) error {
+	return s.upstream.Delete(user)
+}
+
+// Rotate seals the secrets of the users with the primary key if they are sealed with another key or cipher. The users
+// that do not have a secret are skipped. Without users, every key listed by the upstream storage is rotated, and it
+// fails with ErrListNotSupported if the upstream storage can not list its keys.
+func (s *EncryptedStorage) Rotate(users ...string) error {
+	if len(users) == 0 {
+		l, ok := s.upstream.(Lister)
+		if !ok {
+			return ErrListNotSupported
+		}
+
+		keys, err := l.List("")
+		if err != nil {
+			return fmt.Errorf("could not list the secrets to rotate: %w", err)
+		}
+
+		users = keys
+	}
+
+	for _, user := range users {
+		value, err := s.upstream.Get(user)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+
+			return fmt.Errorf("could not rotate %q: %w", user, err)
+		}
+
+		password, current, err := s.open(user, value)
+		if err != nil {
+			return fmt.Errorf("could not rotate %q: %w", user, err)
+		}
+
+		if current {
+			continue
+		}
+
+		if err := s.Set(user, password); err != nil {
+			return fmt.Errorf("could not rotate %q: %w", user, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *EncryptedStorage) seal(user, password string) (string, error) {
+	aead, err := s.aead(s.cipher, s.primary)
+	if err != nil {
+		return "", err
+	}
+
+	header := encryptedValuePrefix + string(s.cipher) + ":" + s.primary + ":"
+
+	sealed, err := seal.SealAEAD(aead, []byte(password), []byte(header+user))
+	if err != nil {
+		return "", err
+	}
+
+	return header + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// open opens the sealed value, and reports whether it is sealed with the primary key and cipher.
+func (s *EncryptedStorage) open(user, value string) (string, bool, error) {
+	if !strings.HasPrefix(value, encryptedValuePrefix) {
+		if s.plaintext {
+			return value, false, nil
+		}
+
+		return "", false, ErrNotEncrypted
+	}
+
+	rest := strings.TrimPrefix(value, encryptedValuePrefix)
+	first := strings.Index(rest, ":")
+	last := strings.LastIndex(rest, ":")
+
+	if first < 0 || first == last {
+		return "", false, fmt.Errorf("%w: invalid header", ErrNotEncrypted)
+	}
+
+	c, keyID := Cipher(rest[:first]), rest[first+1:last]
+
+	sealed, err := base64.RawURLEncoding.DecodeString(rest[last+1:])
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %s", ErrNotEncrypted, err.Error())
+	}
+
+	aead, err := s.aead(c, keyID)
+	if err != nil {
+		return "", false, err
+	}
+
+	password, err := seal.OpenAEAD(aead, sealed, []byte(encryptedValuePrefix+rest[:last+1]+user))
+	if err != nil {
+		return "", false, fmt.Errorf("could not decrypt secret: %w", err)
+	}
+
+	return string(password), c == s.cipher && keyID == s.primary, nil
+}
+
+func (s *EncryptedStorage) aead(c Cipher, keyID string) (cipher.AEAD, error) {
+	k, ok := s.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownEncryptionKey, keyID)
+	}
+
+	key, err := k()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c {
+	case AES256GCM:
+		if len(key) != encryptionKeyLength {
+			return nil, ErrInvalidEncryptionKey
+		}
+
+		return seal.NewAESGCM(key)
+
+	case XChaCha20Poly1305:
+		return seal.NewXChaCha20Poly1305(key)
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedCipher, c)
+}
+
+// NewEncryptedStorage creates a storage that seals the secrets with the primary key using AES-256-GCM by default. The
+// ID of the key is stored with the secret, so the secrets sealed with the keys of WithDecryptionKeys can still be read
+// until they are rotated.
+func NewEncryptedStorage(upstream Storage, primary EncryptionKey, options ...EncryptedStorageOption) *EncryptedStorage {
+	s := &EncryptedStorage{
+		upstream: upstream,
+		cipher:   AES256GCM,
+		primary:  primary.ID,
+		keys: map[string]KeySource{
+			primary.ID: CachedKey(primary.Key),
+		},
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithCipher sets the cipher for sealing the secrets.
+func WithCipher(c Cipher) EncryptedStorageOption {
+	return func(s *EncryptedStorage) {
+		s.cipher = c
+	}
+}
+
+// WithDecryptionKeys adds the keys that are only used for opening the secrets, such as the previous primary keys.
+func WithDecryptionKeys(keys ...EncryptionKey) EncryptedStorageOption {
+	return func(s *EncryptedStorage) {
+		for _, k := range keys {
+			if _, ok := s.keys[k.ID]; !ok {
+				s.keys[k.ID] = CachedKey(k.Key)
+			}
+		}
+	}
+}
+
+// WithPlaintextFallback reads the secrets that are not sealed as is, so an upstream storage with existing secrets can be
+// migrated with Rotate.
+func WithPlaintextFallback() EncryptedStorageOption {
+	return func(s *EncryptedStorage) {
+		s.plaintext = true
+	}
+}
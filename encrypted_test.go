@@ -0,0 +1,263 @@
+package moneyloverkeychain_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+var (
+	testKey1 = moneyloverkeychain.EncryptionKey{
+		ID:  "key-1",
+		Key: moneyloverkeychain.StaticKey([]byte("0123456789abcdef0123456789abcdef")),
+	}
+	testKey2 = moneyloverkeychain.EncryptionKey{
+		ID:  "key-2",
+		Key: moneyloverkeychain.StaticKey([]byte("fedcba9876543210fedcba9876543210")),
+	}
+)
+
+func TestEncryptedStorage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		cipher         moneyloverkeychain.Cipher
+		expectedPrefix string
+	}{
+		{
+			scenario:       "aes-256-gcm",
+			cipher:         moneyloverkeychain.AES256GCM,
+			expectedPrefix: "moneyloverkeychain/encrypted:aes-256-gcm:key-1:",
+		},
+		{
+			scenario:       "xchacha20-poly1305",
+			cipher:         moneyloverkeychain.XChaCha20Poly1305,
+			expectedPrefix: "moneyloverkeychain/encrypted:xchacha20-poly1305:key-1:",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			upstream := moneyloverkeychain.NewMemoryStorage()
+			s := moneyloverkeychain.NewEncryptedStorage(upstream, testKey1, moneyloverkeychain.WithCipher(tc.cipher))
+
+			// Get not found.
+			data, err := s.Get("test")
+
+			assert.Empty(t, data)
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+			// Set.
+			err = s.Set("test", "foobar")
+			require.NoError(t, err)
+
+			data, err = s.Get("test")
+
+			assert.Equal(t, "foobar", data)
+			require.NoError(t, err)
+
+			sealed, err := upstream.Get("test")
+			require.NoError(t, err)
+
+			assert.True(t, strings.HasPrefix(sealed, tc.expectedPrefix))
+			assert.NotContains(t, sealed, "foobar")
+
+			// Delete.
+			err = s.Delete("test")
+			require.NoError(t, err)
+
+			data, err = s.Get("test")
+
+			assert.Empty(t, data)
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+			err = s.Delete("test")
+
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+		})
+	}
+}
+
+func TestEncryptedStorage_Rotate(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	old := moneyloverkeychain.NewEncryptedStorage(upstream, testKey1)
+
+	require.NoError(t, old.Set("a", "foo"))
+	require.NoError(t, old.Set("b", "bar"))
+
+	// The new primary key can read the secrets sealed with the old one.
+	s := moneyloverkeychain.NewEncryptedStorage(upstream, testKey2,
+		moneyloverkeychain.WithCipher(moneyloverkeychain.XChaCha20Poly1305),
+		moneyloverkeychain.WithDecryptionKeys(testKey1),
+	)
+
+	data, err := s.Get("a")
+
+	assert.Equal(t, "foo", data)
+	require.NoError(t, err)
+
+	err = s.Rotate("a", "b", "unknown")
+	require.NoError(t, err)
+
+	for user, sealed := range upstream.Snapshot() {
+		assert.True(t, strings.HasPrefix(sealed, "moneyloverkeychain/encrypted:xchacha20-poly1305:key-2:"), user)
+	}
+
+	// The old key is not needed anymore.
+	s = moneyloverkeychain.NewEncryptedStorage(upstream, testKey2)
+
+	data, err = s.Get("b")
+
+	assert.Equal(t, "bar", data)
+	require.NoError(t, err)
+
+	data, err = old.Get("b")
+
+	assert.Empty(t, data)
+	require.EqualError(t, err, `unknown encryption key: "key-2"`)
+}
+
+func TestEncryptedStorage_RotateAll(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	old := moneyloverkeychain.NewEncryptedStorage(upstream, testKey1)
+
+	require.NoError(t, old.Set("a", "foo"))
+	require.NoError(t, old.Set("b", "bar"))
+
+	s := moneyloverkeychain.NewEncryptedStorage(upstream, testKey2, moneyloverkeychain.WithDecryptionKeys(testKey1))
+
+	require.NoError(t, s.Rotate())
+
+	for user, sealed := range upstream.Snapshot() {
+		assert.True(t, strings.HasPrefix(sealed, "moneyloverkeychain/encrypted:aes-256-gcm:key-2:"), user)
+	}
+
+	// The upstream storage can not list its keys.
+	err := moneyloverkeychain.NewEncryptedStorage(mock.MockStorage()(t), testKey2).Rotate()
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrListNotSupported)
+}
+
+func TestEncryptedStorage_Plaintext(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	upstream.Restore(map[string]string{"test": "foobar"})
+
+	data, err := moneyloverkeychain.NewEncryptedStorage(upstream, testKey1).Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotEncrypted)
+
+	s := moneyloverkeychain.NewEncryptedStorage(upstream, testKey1, moneyloverkeychain.WithPlaintextFallback())
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Rotate("test"))
+
+	sealed, err := upstream.Get("test")
+	require.NoError(t, err)
+
+	assert.NotContains(t, sealed, "foobar")
+}
+
+func TestEncryptedStorage_Tampered(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewEncryptedStorage(upstream, testKey1, moneyloverkeychain.WithDecryptionKeys(testKey2))
+
+	require.NoError(t, s.Set("foo", "foobar"))
+
+	sealed, err := upstream.Get("foo")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		scenario      string
+		user          string
+		value         string
+		expectedError string
+	}{
+		{
+			scenario:      "moved to another user",
+			user:          "bar",
+			value:         sealed,
+			expectedError: "could not decrypt secret: cipher: message authentication failed",
+		},
+		{
+			scenario:      "key id changed",
+			user:          "foo",
+			value:         strings.Replace(sealed, "key-1", "key-2", 1),
+			expectedError: "could not decrypt secret: cipher: message authentication failed",
+		},
+		{
+			scenario:      "unsupported cipher",
+			user:          "foo",
+			value:         strings.Replace(sealed, "aes-256-gcm", "des", 1),
+			expectedError: `unsupported cipher: "des"`,
+		},
+		{
+			scenario:      "invalid header",
+			user:          "foo",
+			value:         "moneyloverkeychain/encrypted:foobar",
+			expectedError: "secret is not encrypted: invalid header",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			upstream := moneyloverkeychain.NewMemoryStorage()
+			upstream.Restore(map[string]string{tc.user: tc.value})
+
+			s := moneyloverkeychain.NewEncryptedStorage(upstream, testKey1, moneyloverkeychain.WithDecryptionKeys(testKey2))
+
+			data, err := s.Get(tc.user)
+
+			assert.Empty(t, data)
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestEncryptedStorage_KeyNotAvailableYet(t *testing.T) {
+	t.Parallel()
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	s := moneyloverkeychain.NewEncryptedStorage(moneyloverkeychain.NewMemoryStorage(),
+		moneyloverkeychain.EncryptionKey{ID: "key-1", Key: moneyloverkeychain.KeyFromFile(keyFile)},
+	)
+
+	err := s.Set("test", "foobar")
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// The key is read again once it is available.
+	require.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0o600))
+	require.NoError(t, s.Set("test", "foobar"))
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+}
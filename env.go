@@ -0,0 +1,103 @@
+package moneyloverkeychain
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+var _ Storage = (*envStorage)(nil)
+
+// EnvStorageOption configures the environment variable storage.
+type EnvStorageOption func(s *envStorage)
+
+type envStorage struct {
+	prefix   string
+	name     func(key string) string
+	writable bool
+}
+
+// Set sets the environment variable of the user for the current process.
+func (s *envStorage) Set(user, password string) error {
+	if !s.writable {
+		return ErrReadOnly
+	}
+
+	return os.Setenv(s.variable(user), password)
+}
+
+// Get gets password from the environment variable of the user.
+func (s *envStorage) Get(user string) (string, error) {
+	password, ok := os.LookupEnv(s.variable(user))
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return password, nil
+}
+
+// Delete unsets the environment variable of the user for the current process.
+func (s *envStorage) Delete(user string) error {
+	if !s.writable {
+		return ErrReadOnly
+	}
+
+	name := s.variable(user)
+
+	if _, ok := os.LookupEnv(name); !ok {
+		return ErrNotFound
+	}
+
+	return os.Unsetenv(name)
+}
+
+func (s *envStorage) variable(user string) string {
+	return s.prefix + s.name(user)
+}
+
+// EnvName converts a key to an environment variable name by upper-casing it and replacing every character that is not
+// a letter or a digit with an underscore.
+func EnvName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return '_'
+		}
+
+		return unicode.ToUpper(r)
+	}, key)
+}
+
+// NewEnvStorage creates a storage that maps keys to environment variables. The storage is read-only unless
+// WithEnvWritable is used.
+func NewEnvStorage(options ...EnvStorageOption) Storage {
+	s := &envStorage{
+		name: EnvName,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithEnvPrefix sets the prefix of the environment variable names.
+func WithEnvPrefix(prefix string) EnvStorageOption {
+	return func(s *envStorage) {
+		s.prefix = prefix
+	}
+}
+
+// WithEnvName sets the mapping from a key to its environment variable name, before the prefix is added.
+func WithEnvName(name func(key string) string) EnvStorageOption {
+	return func(s *envStorage) {
+		s.name = name
+	}
+}
+
+// WithEnvWritable allows setting and unsetting the environment variables of the current process.
+func WithEnvWritable() EnvStorageOption {
+	return func(s *envStorage) {
+		s.writable = true
+	}
+}
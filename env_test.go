@@ -0,0 +1,99 @@
+package moneyloverkeychain_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+func TestEnvName(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		key      string
+		expected string
+	}{
+		{key: "token", expected: "TOKEN"},
+		{key: "user@example.org", expected: "USER_EXAMPLE_ORG"},
+		{key: "b1b1b1b1-1d1d-4e4e-8f8f-2a2a2a2a2a2a", expected: "B1B1B1B1_1D1D_4E4E_8F8F_2A2A2A2A2A2A"},
+		{key: "café", expected: "CAF_"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.key, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, moneyloverkeychain.EnvName(tc.key))
+		})
+	}
+}
+
+func TestEnvStorage_ReadOnly(t *testing.T) {
+	t.Setenv("MONEYLOVER_TOKEN_USER_EXAMPLE_ORG", "foobar")
+
+	s := moneyloverkeychain.NewEnvStorage(moneyloverkeychain.WithEnvPrefix("MONEYLOVER_TOKEN_"))
+
+	data, err := s.Get("user@example.org")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	data, err = s.Get("john@example.org")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	assert.ErrorIs(t, s.Set("user@example.org", "foobaz"), moneyloverkeychain.ErrReadOnly)
+	assert.ErrorIs(t, s.Delete("user@example.org"), moneyloverkeychain.ErrReadOnly)
+
+	assert.Equal(t, "foobar", os.Getenv("MONEYLOVER_TOKEN_USER_EXAMPLE_ORG"))
+}
+
+func TestEnvStorage_Writable(t *testing.T) {
+	// Restore the variable after the test.
+	t.Setenv("token", "")
+
+	s := moneyloverkeychain.NewEnvStorage(
+		moneyloverkeychain.WithEnvWritable(),
+		moneyloverkeychain.WithEnvName(func(key string) string {
+			return key
+		}),
+	)
+
+	require.NoError(t, os.Unsetenv("token"))
+
+	// Get not found.
+	data, err := s.Get("token")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("token", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("token")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "foobar", os.Getenv("token"))
+
+	// Delete.
+	err = s.Delete("token")
+	require.NoError(t, err)
+
+	data, err = s.Get("token")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("token")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
@@ -0,0 +1,328 @@
+package moneyloverkeychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.nhat.io/clock"
+)
+
+const (
+	envelopePrefix  = "moneyloverkeychain/envelope:"
+	envelopeVersion = 1
+)
+
+var (
+	_ MetadataStorage = (*EnvelopeStorage)(nil)
+	_ Lister          = (*EnvelopeStorage)(nil)
+)
+
+// Metadata is the metadata of a secret. The metadata of a secret written without an envelope is empty.
+type Metadata struct {
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Revision  uint64            `json:"revision"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// MetadataStorage is a storage that records the metadata of the secrets.
+type MetadataStorage interface {
+	Storage
+
+	// GetWithMeta gets password and its metadata from keychain.
+	GetWithMeta(user string) (string, Metadata, error)
+	// SetWithMeta sets password with the labels in keychain for user, and returns the new metadata.
+	SetWithMeta(user, password string, labels map[string]string) (Metadata, error)
+}
+
+// EnvelopeStorageOption configures EnvelopeStorage.
+type EnvelopeStorageOption func(s *EnvelopeStorage)
+
+type envelope struct {
+	Metadata
+
+	Version int    `json:"version"`
+	Value   string `json:"value"`
+
+	// Deleted marks a tombstone, which keeps the revision of a deleted secret.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// EnvelopeStorage is a storage that wraps the secrets in an envelope with their metadata.
+type EnvelopeStorage struct {
+	upstream Storage
+	clock    clock.Clock
+	locker   Locker
+
+	mu sync.Mutex
+}
+
+// Set sets password in the upstream storage for user. The labels are kept.
+func (s *EnvelopeStorage) Set(user, password string) error {
+	_, err := s.SetWithMeta(user, password, nil)
+
+	return err
+}
+
+// Get gets password from the upstream storage. The secrets written without an envelope are returned as is.
+func (s *EnvelopeStorage) Get(user string) (string, error) {
+	password, _, err := s.GetWithMeta(user)
+
+	return password, err
+}
+
+// Delete deletes secret from the upstream storage. A tombstone is left in the upstream storage, so that the revision of
+// a secret that is written again continues from the revision of the deleted one. The tombstone stays in the upstream
+// storage until it is purged, see Purge, and is not listed by List.
+func (s *EnvelopeStorage) Delete(user string) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+
+	defer s.unlock()
+
+	previous, err := s.current(user)
+	if err != nil {
+		return err
+	}
+
+	if !previous.exists() {
+		return ErrNotFound
+	}
+
+	return s.delete(user, previous)
+}
+
+// Purge deletes the secret or its tombstone from the upstream storage, so nothing is left of it. The revision of a
+// secret that is written again starts over, so a conditional writer holding a revision read before the purge may
+// overwrite it. Purge only when no conditional writer needs the revision anymore, such as after a logout.
+func (s *EnvelopeStorage) Purge(user string) error {
+	if err := s.lock(); err != nil {
+		return err
+	}
+
+	defer s.unlock()
+
+	return s.upstream.Delete(user)
+}
+
+// List lists the keys of the upstream storage that start with the prefix, without the deleted secrets. It fails with
+// ErrListNotSupported if the upstream storage can not list its keys.
+func (s *EnvelopeStorage) List(prefix string) ([]string, error) {
+	l, ok := s.upstream.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+
+	keys, err := l.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(keys))
+
+	for _, k := range keys {
+		e, err := s.current(k)
+		if err != nil {
+			return nil, fmt.Errorf("could not list %q: %w", k, err)
+		}
+
+		if e.exists() {
+			result = append(result, k)
+		}
+	}
+
+	return result, nil
+}
+
+// GetWithMeta gets password and its metadata from the upstream storage.
+func (s *EnvelopeStorage) GetWithMeta(user string) (string, Metadata, error) {
+	e, err := s.current(user)
+	if err != nil {
+		return "", Metadata{}, err
+	}
+
+	if !e.exists() {
+		return "", Metadata{}, ErrNotFound
+	}
+
+	return e.Value, e.Metadata, nil
+}
+
+// SetWithMeta sets password with the labels in the upstream storage for user. The revision is incremented, and nil
+// labels keep the labels of the previous revision.
+func (s *EnvelopeStorage) SetWithMeta(user, password string, labels map[string]string) (Metadata, error) {
+	if err := s.lock(); err != nil {
+		return Metadata{}, err
+	}
+
+	defer s.unlock()
+
+	previous, err := s.current(user)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	return s.write(user, password, labels, previous)
+}
+
+// lock serializes the writes of this storage, and of the other processes if a locker is set.
+func (s *EnvelopeStorage) lock() error {
+	s.mu.Lock()
+
+	if s.locker == nil {
+		return nil
+	}
+
+	if err := s.locker.Lock(); err != nil {
+		s.mu.Unlock()
+
+		return fmt.Errorf("could not acquire lock: %w", err)
+	}
+
+	return nil
+}
+
+func (s *EnvelopeStorage) unlock() {
+	if s.locker != nil {
+		_ = s.locker.Unlock() // nolint: errcheck
+	}
+
+	s.mu.Unlock()
+}
+
+// current returns the envelope of the secret, which is a tombstone if the secret was deleted, or nil if it was never
+// written.
+func (s *EnvelopeStorage) current(user string) (*envelope, error) {
+	data, err := s.upstream.Get(user)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	e, err := openEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (s *EnvelopeStorage) write(user, password string, labels map[string]string, previous *envelope) (Metadata, error) {
+	now := s.clock.Now().UTC()
+	meta := Metadata{
+		CreatedAt: now,
+		UpdatedAt: now,
+		Revision:  1,
+	}
+
+	if labels != nil {
+		meta.Labels = copySecrets(labels)
+	}
+
+	if previous != nil {
+		meta.Revision = previous.Revision + 1
+	}
+
+	if previous.exists() {
+		if !previous.CreatedAt.IsZero() {
+			meta.CreatedAt = previous.CreatedAt
+		}
+
+		if labels == nil {
+			meta.Labels = previous.Labels
+		}
+	}
+
+	if err := s.put(user, envelope{Metadata: meta, Value: password}); err != nil {
+		return Metadata{}, err
+	}
+
+	return meta, nil
+}
+
+// delete replaces the secret with a tombstone.
+func (s *EnvelopeStorage) delete(user string, previous *envelope) error {
+	return s.put(user, envelope{
+		Metadata: Metadata{
+			UpdatedAt: s.clock.Now().UTC(),
+			Revision:  previous.Revision + 1,
+		},
+		Deleted: true,
+	})
+}
+
+func (s *EnvelopeStorage) put(user string, e envelope) error {
+	e.Version = envelopeVersion
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return s.upstream.Set(user, envelopePrefix+string(data))
+}
+
+// exists reports whether the envelope holds a secret.
+func (e *envelope) exists() bool {
+	return e != nil && !e.Deleted
+}
+
+func openEnvelope(data string) (envelope, error) {
+	if !strings.HasPrefix(data, envelopePrefix) {
+		return envelope{Value: data}, nil
+	}
+
+	var e envelope
+
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, envelopePrefix)), &e); err != nil {
+		return envelope{}, fmt.Errorf("could not unmarshal envelope: %w", err)
+	}
+
+	if e.Version != envelopeVersion {
+		return envelope{}, fmt.Errorf("unsupported envelope version %d", e.Version)
+	}
+
+	return e, nil
+}
+
+// NewEnvelopeStorage creates a storage that wraps the secrets in an envelope recording the creation and the update
+// time, a revision incremented on every write and delete, and labels. The deleted secrets are replaced with tombstones,
+// which stay in the upstream storage.
+//
+// The writes are serialized within the storage. The storages of other processes are only serialized with a shared
+// locker, see WithEnvelopeLocker.
+func NewEnvelopeStorage(upstream Storage, options ...EnvelopeStorageOption) *EnvelopeStorage {
+	s := &EnvelopeStorage{
+		upstream: upstream,
+		clock:    clock.New(),
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithEnvelopeClock sets the clock of the envelope storage.
+func WithEnvelopeClock(c clock.Clock) EnvelopeStorageOption {
+	return func(s *EnvelopeStorage) {
+		s.clock = c
+	}
+}
+
+// WithEnvelopeLocker sets a lock that is held while writing, such as NewFileLocker, so that the storages of several
+// processes sharing the same upstream storage do not change a secret between checking and writing its revision.
+func WithEnvelopeLocker(l Locker) EnvelopeStorageOption {
+	return func(s *EnvelopeStorage) {
+		s.locker = l
+	}
+}
@@ -0,0 +1,217 @@
+package moneyloverkeychain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/credentials"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+func TestEnvelopeStorage(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClock()
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewEnvelopeStorage(upstream, moneyloverkeychain.WithEnvelopeClock(c))
+
+	// Get not found.
+	data, meta, err := s.GetWithMeta("test")
+
+	assert.Empty(t, data)
+	assert.Equal(t, moneyloverkeychain.Metadata{}, meta)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	created := c.Now()
+
+	meta, err = s.SetWithMeta("test", "foobar", map[string]string{"owner": "reporting"})
+	require.NoError(t, err)
+
+	expected := moneyloverkeychain.Metadata{
+		CreatedAt: created,
+		UpdatedAt: created,
+		Revision:  1,
+		Labels:    map[string]string{"owner": "reporting"},
+	}
+
+	assert.Equal(t, expected, meta)
+
+	expectedEnvelope := `moneyloverkeychain/envelope:{"created_at":"2020-01-02T03:04:05Z","updated_at":"2020-01-02T03:04:05Z",` +
+		`"revision":1,"labels":{"owner":"reporting"},"version":1,"value":"foobar"}`
+
+	assert.Equal(t, map[string]string{"test": expectedEnvelope}, upstream.Snapshot())
+
+	// Update keeps the labels.
+	c.Add(time.Hour)
+
+	require.NoError(t, s.Set("test", "foobaz"))
+
+	data, meta, err = s.GetWithMeta("test")
+
+	expected = moneyloverkeychain.Metadata{
+		CreatedAt: created,
+		UpdatedAt: created.Add(time.Hour),
+		Revision:  2,
+		Labels:    map[string]string{"owner": "reporting"},
+	}
+
+	assert.Equal(t, "foobaz", data)
+	assert.Equal(t, expected, meta)
+	require.NoError(t, err)
+
+	// Update replaces the labels.
+	meta, err = s.SetWithMeta("test", "foobaz", map[string]string{})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(3), meta.Revision)
+	assert.Empty(t, meta.Labels)
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// A tombstone keeps the revision.
+	expectedTombstone := `moneyloverkeychain/envelope:{"created_at":"0001-01-01T00:00:00Z",` +
+		`"updated_at":"2020-01-02T04:04:05Z","revision":4,"version":1,"value":"","deleted":true}`
+
+	assert.Equal(t, map[string]string{"test": expectedTombstone}, upstream.Snapshot())
+
+	// The revision continues from the deleted secret.
+	meta, err = s.SetWithMeta("test", "foobar", nil)
+	require.NoError(t, err)
+
+	expected = moneyloverkeychain.Metadata{
+		CreatedAt: created.Add(time.Hour),
+		UpdatedAt: created.Add(time.Hour),
+		Revision:  5,
+	}
+
+	assert.Equal(t, expected, meta)
+}
+
+func TestEnvelopeStorage_List(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewIndexedStorage(moneyloverkeychain.NewMemoryStorage())
+	s := moneyloverkeychain.NewEnvelopeStorage(upstream)
+
+	require.NoError(t, s.Set("token-a", "a"))
+	require.NoError(t, s.Set("token-b", "b"))
+	require.NoError(t, s.Delete("token-a"))
+
+	keys, err := s.List("token-")
+
+	assert.Equal(t, []string{"token-b"}, keys)
+	require.NoError(t, err)
+
+	// The tombstone stays in the upstream storage.
+	keys, err = upstream.List("token-")
+
+	assert.Equal(t, []string{"token-a", "token-b"}, keys)
+	require.NoError(t, err)
+
+	// The upstream storage can not list its keys.
+	keys, err = moneyloverkeychain.NewEnvelopeStorage(mock.MockStorage()(t)).List("")
+
+	assert.Empty(t, keys)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrListNotSupported)
+}
+
+func TestEnvelopeStorage_Legacy(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClock()
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	upstream.Restore(map[string]string{"test": `{"username":"user@example.org","password":"123456"}`})
+
+	s := moneyloverkeychain.NewEnvelopeStorage(upstream, moneyloverkeychain.WithEnvelopeClock(c))
+
+	data, meta, err := s.GetWithMeta("test")
+
+	assert.Equal(t, `{"username":"user@example.org","password":"123456"}`, data)
+	assert.Equal(t, moneyloverkeychain.Metadata{}, meta)
+	require.NoError(t, err)
+
+	meta, err = s.SetWithMeta("test", "foobar", nil)
+	require.NoError(t, err)
+
+	expected := moneyloverkeychain.Metadata{
+		CreatedAt: c.Now(),
+		UpdatedAt: c.Now(),
+		Revision:  1,
+	}
+
+	assert.Equal(t, expected, meta)
+}
+
+func TestEnvelopeStorage_Invalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		value         string
+		expectedError string
+	}{
+		{
+			scenario:      "not json",
+			value:         "moneyloverkeychain/envelope:{",
+			expectedError: "could not unmarshal envelope: unexpected end of JSON input",
+		},
+		{
+			scenario:      "unsupported version",
+			value:         `moneyloverkeychain/envelope:{"version":2}`,
+			expectedError: "unsupported envelope version 2",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			upstream := moneyloverkeychain.NewMemoryStorage()
+			upstream.Restore(map[string]string{"test": tc.value})
+
+			data, err := moneyloverkeychain.NewEnvelopeStorage(upstream).Get("test")
+
+			assert.Empty(t, data)
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestEnvelopeStorage_Credentials(t *testing.T) {
+	t.Parallel()
+
+	deviceID := uuid.New()
+	s := moneyloverkeychain.NewEnvelopeStorage(moneyloverkeychain.NewMemoryStorage())
+
+	err := credentials.New(deviceID, credentials.WithStorage(s)).Update("user@example.org", "123456")
+	require.NoError(t, err)
+
+	c := credentials.New(deviceID, credentials.WithStorage(s))
+
+	assert.Equal(t, "user@example.org", c.Username())
+	assert.Equal(t, "123456", c.Password())
+
+	_, meta, err := s.GetWithMeta(deviceID.String())
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), meta.Revision)
+	assert.False(t, meta.UpdatedAt.IsZero())
+}
@@ -0,0 +1,35 @@
+package moneyloverkeychain
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+
+	"github.com/nhatthm/moneyloverkeychain/internal/fsutil"
+)
+
+// ErrNotFound indicates that the secret does not exist in the storage.
+//
+// It is the same error as keyring.ErrNotFound so the storages are interchangeable with the keychain storage.
+var ErrNotFound = keyring.ErrNotFound
+
+// ErrInsecurePermissions indicates that a file is accessible by group or others.
+var ErrInsecurePermissions = fsutil.ErrInsecurePermissions
+
+// ErrInvalidVault indicates that a vault file could not be parsed.
+var ErrInvalidVault = errors.New("invalid vault")
+
+// ErrReadOnly indicates that the storage does not support writing.
+var ErrReadOnly = errors.New("storage is read-only")
+
+// ErrListNotSupported indicates that the storage can not list its keys.
+var ErrListNotSupported = errors.New("storage can not list its keys")
+
+// ErrInvalidEncryptionKey indicates that an encryption key is not 256-bit.
+var ErrInvalidEncryptionKey = errors.New("encryption key must be 32 bytes, hex or base64 encoded")
+
+// ErrLockNotSupported indicates that file locks are not supported on the platform.
+var ErrLockNotSupported = fsutil.ErrLockNotSupported
+
+// ErrConflict indicates that the secret was changed by someone else since its revision was read.
+var ErrConflict = errors.New("revision conflict")
@@ -0,0 +1,375 @@
+package moneyloverkeychain
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/nhatthm/moneyloverkeychain/internal/fsutil"
+)
+
+const (
+	fileVaultVersion = 1
+	fileKeyLength    = 32
+	fileSaltLength   = 16
+
+	kdfArgon2id = "argon2id"
+	kdfScrypt   = "scrypt"
+
+	// The parameters of the key derivation are read from the unauthenticated header before the key is derived, so they
+	// are limited to keep a malformed vault from exhausting the memory or the CPU.
+	maxKDFMemory  = 1 << 30 // bytes
+	maxArgon2Time = 16
+	maxScryptN    = 1 << 20
+	maxScryptCost = 16 // r * p
+)
+
+var _ Storage = (*fileStorage)(nil)
+
+// FileStorageOption configures the file storage.
+type FileStorageOption func(s *fileStorage)
+
+// fileHeader is the unencrypted part of the vault file. It is authenticated as additional data of the ciphertext.
+type fileHeader struct {
+	Version int     `json:"version"`
+	KDF     fileKDF `json:"kdf"`
+}
+
+type fileKDF struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt"`
+
+	// Argon2id parameters.
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+
+	// Scrypt parameters.
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+}
+
+type fileVault struct {
+	fileHeader
+
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// fileEntries maps service to key to secret.
+type fileEntries map[string]map[string]string
+
+type fileStorage struct {
+	mu sync.Mutex
+
+	path       string
+	passphrase []byte
+	service    string
+	kdf        fileKDF
+
+	// The derived key is cached because the key derivation is intentionally slow.
+	key    []byte
+	keyKDF []byte
+}
+
+// Set sets password in the vault for user.
+func (s *fileStorage) Set(user, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := fsutil.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	defer unlock() // nolint: errcheck
+
+	entries, header, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if entries[s.service] == nil {
+		entries[s.service] = make(map[string]string)
+	}
+
+	entries[s.service][user] = password
+
+	return s.write(header, entries)
+}
+
+// Get gets password from the vault.
+func (s *fileStorage) Get(user string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, _, err := s.read()
+	if err != nil {
+		return "", err
+	}
+
+	password, ok := entries[s.service][user]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return password, nil
+}
+
+// Delete deletes secret from the vault.
+func (s *fileStorage) Delete(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := fsutil.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	defer unlock() // nolint: errcheck
+
+	entries, header, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := entries[s.service][user]; !ok {
+		return ErrNotFound
+	}
+
+	delete(entries[s.service], user)
+
+	if len(entries[s.service]) == 0 {
+		delete(entries, s.service)
+	}
+
+	return s.write(header, entries)
+}
+
+// read reads and decrypts the vault. If the vault does not exist, a new header is returned.
+func (s *fileStorage) read() (fileEntries, fileHeader, error) {
+	data, err := fsutil.ReadPrivateFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			header, err := s.newHeader()
+
+			return fileEntries{}, header, err
+		}
+
+		return nil, fileHeader{}, err
+	}
+
+	var v fileVault
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fileHeader{}, fmt.Errorf("%w: %s", ErrInvalidVault, err.Error())
+	}
+
+	if v.Version != fileVaultVersion {
+		return nil, fileHeader{}, fmt.Errorf("%w: unsupported version %d", ErrInvalidVault, v.Version)
+	}
+
+	aead, err := s.aead(v.KDF)
+	if err != nil {
+		return nil, fileHeader{}, err
+	}
+
+	ad, err := json.Marshal(v.fileHeader)
+	if err != nil {
+		return nil, fileHeader{}, err
+	}
+
+	if len(v.Nonce) != aead.NonceSize() {
+		return nil, fileHeader{}, fmt.Errorf("%w: invalid nonce", ErrInvalidVault)
+	}
+
+	plaintext, err := aead.Open(nil, v.Nonce, v.Ciphertext, ad)
+	if err != nil {
+		return nil, fileHeader{}, fmt.Errorf("could not decrypt vault: %w", err)
+	}
+
+	entries := fileEntries{}
+
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fileHeader{}, fmt.Errorf("%w: %s", ErrInvalidVault, err.Error())
+	}
+
+	return entries, v.fileHeader, nil
+}
+
+// write encrypts and writes the vault atomically.
+func (s *fileStorage) write(header fileHeader, entries fileEntries) error {
+	aead, err := s.aead(header.KDF)
+	if err != nil {
+		return err
+	}
+
+	ad, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(fileVault{
+		fileHeader: header,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, ad),
+	})
+	if err != nil {
+		return err
+	}
+
+	return fsutil.WriteFileAtomic(s.path, data)
+}
+
+// lockPath returns the path of the file that is locked while writing, so that the processes sharing the vault do not
+// lose each other's changes.
+func (s *fileStorage) lockPath() string {
+	return s.path + ".lock"
+}
+
+func (s *fileStorage) newHeader() (fileHeader, error) {
+	kdf := s.kdf
+	kdf.Salt = make([]byte, fileSaltLength)
+
+	if _, err := io.ReadFull(rand.Reader, kdf.Salt); err != nil {
+		return fileHeader{}, err
+	}
+
+	return fileHeader{Version: fileVaultVersion, KDF: kdf}, nil
+}
+
+func (s *fileStorage) aead(kdf fileKDF) (cipher.AEAD, error) {
+	params, err := json.Marshal(kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.key == nil || !bytes.Equal(s.keyKDF, params) {
+		key, err := deriveFileKey(s.passphrase, kdf)
+		if err != nil {
+			return nil, err
+		}
+
+		s.key = key
+		s.keyKDF = params
+	}
+
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func deriveFileKey(passphrase []byte, kdf fileKDF) ([]byte, error) {
+	switch kdf.Algorithm {
+	case kdfArgon2id:
+		if kdf.Time == 0 || kdf.Memory == 0 || kdf.Threads == 0 {
+			return nil, fmt.Errorf("%w: invalid argon2id parameters", ErrInvalidVault)
+		}
+
+		if kdf.Time > maxArgon2Time || uint64(kdf.Memory)*1024 > maxKDFMemory {
+			return nil, fmt.Errorf("%w: argon2id parameters exceed the limits", ErrInvalidVault)
+		}
+
+		return argon2.IDKey(passphrase, kdf.Salt, kdf.Time, kdf.Memory, kdf.Threads, fileKeyLength), nil
+
+	case kdfScrypt:
+		if kdf.N <= 0 || kdf.R <= 0 || kdf.P <= 0 {
+			return nil, fmt.Errorf("%w: invalid scrypt parameters", ErrInvalidVault)
+		}
+
+		if kdf.N > maxScryptN || kdf.R*kdf.P > maxScryptCost || uint64(kdf.N)*uint64(kdf.R)*128 > maxKDFMemory {
+			return nil, fmt.Errorf("%w: scrypt parameters exceed the limits", ErrInvalidVault)
+		}
+
+		key, err := scrypt.Key(passphrase, kdf.Salt, kdf.N, kdf.R, kdf.P, fileKeyLength)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidVault, err.Error())
+		}
+
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%w: unsupported kdf %q", ErrInvalidVault, kdf.Algorithm)
+}
+
+// NewFileStorage creates a storage on top of an encrypted vault file. The key is derived from the passphrase using
+// argon2id by default, the parameters are recorded in the file header.
+//
+// The vault is created on the first write. A vault file that is accessible by group or others is refused. The writes
+// lock a file next to the vault with the ".lock" suffix.
+func NewFileStorage(path, passphrase string, options ...FileStorageOption) Storage {
+	s := &fileStorage{
+		path:       path,
+		passphrase: []byte(passphrase),
+		kdf: fileKDF{
+			Algorithm: kdfArgon2id,
+			Time:      3,
+			Memory:    64 * 1024,
+			Threads:   4,
+		},
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithFileService sets the service of the file storage so that several storages can share the same vault.
+func WithFileService(service string) FileStorageOption {
+	return func(s *fileStorage) {
+		s.service = service
+	}
+}
+
+// WithArgon2id derives the key of a new vault using argon2id. The memory is in KiB, up to 1 GiB, and the time is up to
+// 16.
+func WithArgon2id(time, memory uint32, threads uint8) FileStorageOption {
+	return func(s *fileStorage) {
+		s.kdf = fileKDF{
+			Algorithm: kdfArgon2id,
+			Time:      time,
+			Memory:    memory,
+			Threads:   threads,
+		}
+	}
+}
+
+// WithScrypt derives the key of a new vault using scrypt. N is up to 2^20, r * p up to 16, and the memory, 128 * N * r
+// bytes, up to 1 GiB.
+func WithScrypt(n, r, p int) FileStorageOption {
+	return func(s *fileStorage) {
+		s.kdf = fileKDF{
+			Algorithm: kdfScrypt,
+			N:         n,
+			R:         r,
+			P:         p,
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package moneyloverkeychain_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+func newTestFileStorage(path string, options ...moneyloverkeychain.FileStorageOption) moneyloverkeychain.Storage {
+	options = append([]moneyloverkeychain.FileStorageOption{moneyloverkeychain.WithArgon2id(1, 64, 1)}, options...)
+
+	return moneyloverkeychain.NewFileStorage(path, "passphrase", options...)
+}
+
+func TestFileStorage(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault")
+	s := newTestFileStorage(path)
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	content, err := os.ReadFile(filepath.Clean(path))
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(content), "foobar")
+	assert.Contains(t, string(content), `"algorithm":"argon2id"`)
+
+	// Reopen.
+	data, err = newTestFileStorage(path).Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestFileStorage_Scrypt(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault")
+	s := moneyloverkeychain.NewFileStorage(path, "passphrase", moneyloverkeychain.WithScrypt(1024, 8, 1))
+
+	err := s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	// The parameters are read from the header, not from the options.
+	data, err := newTestFileStorage(path).Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+}
+
+func TestFileStorage_Services(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault")
+	credentials := newTestFileStorage(path, moneyloverkeychain.WithFileService("credentials"))
+	token := newTestFileStorage(path, moneyloverkeychain.WithFileService("token"))
+
+	require.NoError(t, credentials.Set("test", "credentials"))
+	require.NoError(t, token.Set("test", "token"))
+
+	data, err := credentials.Get("test")
+
+	assert.Equal(t, "credentials", data)
+	require.NoError(t, err)
+
+	data, err = token.Get("test")
+
+	assert.Equal(t, "token", data)
+	require.NoError(t, err)
+
+	require.NoError(t, token.Delete("test"))
+
+	data, err = credentials.Get("test")
+
+	assert.Equal(t, "credentials", data)
+	require.NoError(t, err)
+}
+
+func TestFileStorage_WrongPassphrase(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault")
+
+	err := newTestFileStorage(path).Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err := moneyloverkeychain.NewFileStorage(path, "wrong").Get("test")
+
+	assert.Empty(t, data)
+	require.EqualError(t, err, "could not decrypt vault: cipher: message authentication failed")
+}
+
+func TestFileStorage_InsecurePermissions(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vault")
+	s := newTestFileStorage(path)
+
+	err := s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	err = os.Chmod(path, 0o644)
+	require.NoError(t, err)
+
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrInsecurePermissions)
+
+	err = s.Set("test", "foobar")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrInsecurePermissions)
+}
+
+func TestFileStorage_InvalidVault(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		content       string
+		expectedError string
+	}{
+		{
+			scenario:      "not json",
+			content:       "{",
+			expectedError: "invalid vault: unexpected end of JSON input",
+		},
+		{
+			scenario:      "unsupported version",
+			content:       `{"version":2}`,
+			expectedError: "invalid vault: unsupported version 2",
+		},
+		{
+			scenario:      "unsupported kdf",
+			content:       `{"version":1,"kdf":{"algorithm":"pbkdf2"}}`,
+			expectedError: `invalid vault: unsupported kdf "pbkdf2"`,
+		},
+		{
+			scenario:      "argon2id memory too large",
+			content:       `{"version":1,"kdf":{"algorithm":"argon2id","time":1,"memory":4294967295,"threads":1}}`,
+			expectedError: "invalid vault: argon2id parameters exceed the limits",
+		},
+		{
+			scenario:      "argon2id time too large",
+			content:       `{"version":1,"kdf":{"algorithm":"argon2id","time":4294967295,"memory":1024,"threads":1}}`,
+			expectedError: "invalid vault: argon2id parameters exceed the limits",
+		},
+		{
+			scenario:      "scrypt n too large",
+			content:       `{"version":1,"kdf":{"algorithm":"scrypt","n":2097152,"r":1,"p":1}}`,
+			expectedError: "invalid vault: scrypt parameters exceed the limits",
+		},
+		{
+			scenario:      "scrypt memory too large",
+			content:       `{"version":1,"kdf":{"algorithm":"scrypt","n":1048576,"r":16,"p":1}}`,
+			expectedError: "invalid vault: scrypt parameters exceed the limits",
+		},
+		{
+			scenario:      "scrypt invalid parameters",
+			content:       `{"version":1,"kdf":{"algorithm":"scrypt","n":1024,"r":0,"p":1}}`,
+			expectedError: "invalid vault: invalid scrypt parameters",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "vault")
+
+			err := os.WriteFile(path, []byte(tc.content), 0o600)
+			require.NoError(t, err)
+
+			_, err = newTestFileStorage(path).Get("test")
+
+			require.EqualError(t, err, tc.expectedError)
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrInvalidVault)
+		})
+	}
+}
+
+func TestFileStorage_NoTemporaryFilesLeft(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s := newTestFileStorage(filepath.Join(dir, "vault"))
+
+	require.NoError(t, s.Set("foo", "bar"))
+	require.NoError(t, s.Set("foo", "baz"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	assert.Equal(t, "vault,vault.lock", strings.Join(names, ","))
+}
+
+func TestFileStorage_ConcurrentProcesses(t *testing.T) {
+	t.Parallel()
+
+	const writers = 4
+
+	path := filepath.Join(t.TempDir(), "vault")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		// Every writer has its own storage, like separate processes.
+		s := newTestFileStorage(path)
+		key := fmt.Sprintf("key%d", i)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, s.Set(key, "value"))
+		}()
+	}
+
+	wg.Wait()
+
+	s := newTestFileStorage(path)
+
+	for i := 0; i < writers; i++ {
+		data, err := s.Get(fmt.Sprintf("key%d", i))
+
+		assert.Equal(t, "value", data)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,2 @@
+// Package gitcred provides a storage that delegates to git credential helpers.
+package gitcred
@@ -0,0 +1,235 @@
+package gitcred
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+const (
+	actionGet   = "get"
+	actionStore = "store"
+	actionErase = "erase"
+)
+
+// gitActions maps the helper actions to the actions of git credential.
+var gitActions = map[string]string{
+	actionGet:   "fill",
+	actionStore: "approve",
+	actionErase: "reject",
+}
+
+var _ moneyloverkeychain.Storage = (*Storage)(nil)
+
+// ErrInvalidAttribute indicates that a username or a password can not be sent in the line-based protocol.
+var ErrInvalidAttribute = errors.New("credential attribute must not contain a new line or a null character")
+
+// Option configures Storage.
+type Option func(s *Storage)
+
+// Storage is a storage that talks the git credential protocol. The service is the host and the key is the username of
+// the credentials.
+//
+// By default, it runs "git credential fill|approve|reject" so the credential helpers configured in git are used, with
+// the terminal prompts disabled. WithHelper talks to a single helper instead.
+type Storage struct {
+	git      string
+	helper   string
+	protocol string
+	host     string
+	env      []string
+}
+
+// Set sets password in the credential helper for user.
+func (s *Storage) Set(user, password string) error {
+	input, err := s.attributes(user, password)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.run(actionStore, input)
+
+	return err
+}
+
+// Get gets password from the credential helper.
+func (s *Storage) Get(user string) (string, error) {
+	input, err := s.attributes(user, "")
+	if err != nil {
+		return "", err
+	}
+
+	out, err := s.run(actionGet, input)
+	if err != nil {
+		return "", err
+	}
+
+	attrs := parseAttributes(out)
+
+	password, ok := attrs["password"]
+	if !ok || (attrs["username"] != "" && attrs["username"] != user) {
+		return "", moneyloverkeychain.ErrNotFound
+	}
+
+	return password, nil
+}
+
+// Delete deletes secret from the credential helper.
+func (s *Storage) Delete(user string) error {
+	// The protocol does not tell whether the credentials existed.
+	if _, err := s.Get(user); err != nil {
+		return err
+	}
+
+	input, err := s.attributes(user, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.run(actionErase, input)
+
+	return err
+}
+
+func (s *Storage) attributes(user, password string) ([]byte, error) {
+	// A new line would inject other attributes.
+	if strings.ContainsAny(user, "\n\x00") {
+		return nil, fmt.Errorf("%w: username %q", ErrInvalidAttribute, user)
+	}
+
+	if strings.ContainsAny(password, "\n\x00") {
+		return nil, fmt.Errorf("%w: password", ErrInvalidAttribute)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "protocol=%s\nhost=%s\nusername=%s\n", s.protocol, s.host, user)
+
+	if password != "" {
+		fmt.Fprintf(&buf, "password=%s\n", password)
+	}
+
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+func (s *Storage) command(action string) *exec.Cmd {
+	switch {
+	case s.helper == "":
+		return exec.Command(s.git, "credential", gitActions[action]) // nolint: gosec
+
+	case strings.HasPrefix(s.helper, "!"):
+		return exec.Command("sh", "-c", s.helper[1:]+" \"$@\"", s.helper[1:], action) // nolint: gosec
+	}
+
+	args := strings.Fields(s.helper)
+
+	if filepath.IsAbs(args[0]) {
+		return exec.Command(args[0], append(args[1:], action)...) // nolint: gosec
+	}
+
+	return exec.Command(s.git, append([]string{"credential-" + args[0]}, append(args[1:], action)...)...) // nolint: gosec
+}
+
+func (s *Storage) run(action string, input []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := s.command(action)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	cmd.Env = s.env
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+
+	// Never prompt for the missing credentials.
+	cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+
+		// git credential fill fails when no helper has the credentials and it can not prompt. The other failures, such
+		// as a missing git or a broken helper, are not hidden.
+		if s.helper == "" && action == actionGet && strings.Contains(msg, "terminal prompts disabled") {
+			return nil, moneyloverkeychain.ErrNotFound
+		}
+
+		if msg != "" {
+			return nil, fmt.Errorf("git credential %s: %w: %s", action, err, msg)
+		}
+
+		return nil, fmt.Errorf("git credential %s: %w", action, err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func parseAttributes(data []byte) map[string]string {
+	attrs := make(map[string]string)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+
+	for sc.Scan() {
+		if k, v, ok := strings.Cut(sc.Text(), "="); ok {
+			attrs[k] = v
+		}
+	}
+
+	return attrs
+}
+
+// New creates a storage that delegates to git credential helpers, with the service as the host.
+func New(service string, options ...Option) *Storage {
+	s := &Storage{
+		git:      "git",
+		protocol: "https",
+		host:     service,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithHelper talks to a single helper, which is specified like credential.helper in git config: a name such as
+// "store --file=/path" runs "git credential-store --file=/path", an absolute path is run as is and a value starting
+// with "!" is run by the shell.
+func WithHelper(helper string) Option {
+	return func(s *Storage) {
+		s.helper = strings.TrimSpace(helper)
+	}
+}
+
+// WithProtocol sets the protocol of the credentials, which is "https" by default.
+func WithProtocol(protocol string) Option {
+	return func(s *Storage) {
+		s.protocol = protocol
+	}
+}
+
+// WithGit sets the git binary.
+func WithGit(git string) Option {
+	return func(s *Storage) {
+		s.git = git
+	}
+}
+
+// WithEnv sets the environment of git and the helpers. By default, they inherit the environment of the current
+// process.
+func WithEnv(env []string) Option {
+	return func(s *Storage) {
+		s.env = env
+	}
+}
@@ -0,0 +1,339 @@
+package moneyloverkeychain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bool64/ctxd"
+)
+
+const (
+	// DefaultIndexKey is the key of the index entry.
+	DefaultIndexKey = "moneyloverkeychain/index"
+
+	indexVersion = 1
+)
+
+// errInvalidIndex indicates that the index entry can not be decoded.
+var errInvalidIndex = errors.New("invalid index")
+
+var (
+	_ Storage = (*IndexedStorage)(nil)
+	_ Lister  = (*IndexedStorage)(nil)
+)
+
+// Lister lists the keys of a storage.
+type Lister interface {
+	// List lists the keys that start with the prefix, in lexical order.
+	List(prefix string) ([]string, error)
+}
+
+// IndexedStorageOption configures IndexedStorage.
+type IndexedStorageOption func(s *IndexedStorage)
+
+type index struct {
+	Version int      `json:"version"`
+	Keys    []string `json:"keys"`
+}
+
+// IndexedStorage is a storage that maintains the list of its keys in an index entry of the upstream storage, for the
+// storages that can not enumerate their keys, such as the system keyring.
+//
+// The index is updated after the secret is written to the upstream storage. The update is best-effort: a failure is
+// logged and does not fail the write, and the index is brought back in sync by Repair. The index entry is removed when
+// there is no key left.
+type IndexedStorage struct {
+	upstream Storage
+	key      string
+	locker   Locker
+	logger   ctxd.Logger
+
+	mu sync.Mutex
+}
+
+// Set sets password in the upstream storage for user and adds the user to the index.
+func (s *IndexedStorage) Set(user, password string) error {
+	if err := s.checkKey(user); err != nil {
+		return err
+	}
+
+	if err := s.upstream.Set(user, password); err != nil {
+		return err
+	}
+
+	s.updateIndex("set", user, func(keys map[string]struct{}) {
+		keys[user] = struct{}{}
+	})
+
+	return nil
+}
+
+// Get gets password from the upstream storage.
+func (s *IndexedStorage) Get(user string) (string, error) {
+	if err := s.checkKey(user); err != nil {
+		return "", err
+	}
+
+	return s.upstream.Get(user)
+}
+
+// Delete deletes secret from the upstream storage and removes the user from the index.
+func (s *IndexedStorage) Delete(user string) error {
+	if err := s.checkKey(user); err != nil {
+		return err
+	}
+
+	err := s.upstream.Delete(user)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	s.updateIndex("delete", user, func(keys map[string]struct{}) {
+		delete(keys, user)
+	})
+
+	return err
+}
+
+// List lists the indexed keys that start with the prefix.
+func (s *IndexedStorage) List(prefix string) ([]string, error) {
+	keys, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(keys))
+
+	for k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			result = append(result, k)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// Repair brings the index in sync with the upstream storage. The indexed keys that do not exist anymore are removed,
+// and the candidates that exist are added, such as the keys written by a storage without the index. An index that can
+// not be decoded is logged and rebuilt from the candidates.
+func (s *IndexedStorage) Repair(candidates ...string) error {
+	indexed, err := s.read()
+	if err != nil {
+		if !errors.Is(err, errInvalidIndex) {
+			return err
+		}
+
+		s.logger.Warn(context.Background(), "could not decode the index of the storage, rebuilding it", "error", err)
+
+		indexed = map[string]struct{}{}
+	}
+
+	exists := make(map[string]bool, len(indexed)+len(candidates))
+
+	for _, keys := range [][]string{mapKeys(indexed), candidates} {
+		for _, k := range keys {
+			if _, ok := exists[k]; ok || k == s.key {
+				continue
+			}
+
+			_, err := s.upstream.Get(k)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("could not repair index: %w", err)
+			}
+
+			exists[k] = err == nil
+		}
+	}
+
+	return s.apply(true, func(keys map[string]struct{}) {
+		for k, ok := range exists {
+			if ok {
+				keys[k] = struct{}{}
+			} else {
+				delete(keys, k)
+			}
+		}
+	})
+}
+
+func (s *IndexedStorage) checkKey(user string) error {
+	if user == s.key {
+		return fmt.Errorf("%w: %q is reserved for the index", ErrInvalidKey, user)
+	}
+
+	return nil
+}
+
+// updateIndex applies the change to the index after a write to the upstream storage. The write has already happened, so
+// a failure is only logged.
+func (s *IndexedStorage) updateIndex(op, user string, change func(keys map[string]struct{})) {
+	if err := s.update(change); err != nil {
+		s.logger.Warn(context.Background(), "could not update the index of the storage",
+			"operation", op, "key", user, "error", err,
+		)
+	}
+}
+
+// update applies the change to the index. The index is read and written under the lock, so that the writers sharing the
+// locker do not lose each other's changes.
+func (s *IndexedStorage) update(change func(keys map[string]struct{})) error {
+	return s.apply(false, change)
+}
+
+// apply applies the change to the index under the lock. If rebuild is true, an index that can not be decoded is replaced
+// instead of failing the change.
+func (s *IndexedStorage) apply(rebuild bool, change func(keys map[string]struct{})) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locker != nil {
+		if err := s.locker.Lock(); err != nil {
+			return fmt.Errorf("could not lock index: %w", err)
+		}
+
+		defer s.locker.Unlock() // nolint: errcheck
+	}
+
+	keys, err := s.read()
+	invalid := rebuild && errors.Is(err, errInvalidIndex)
+
+	switch {
+	case invalid:
+		keys = map[string]struct{}{}
+
+	case err != nil:
+		return err
+	}
+
+	before := mapKeys(keys)
+
+	change(keys)
+
+	if !invalid && sameStrings(before, mapKeys(keys)) {
+		return nil
+	}
+
+	if len(keys) == 0 {
+		if err := s.upstream.Delete(s.key); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("could not remove index: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := s.write(keys); err != nil {
+		return fmt.Errorf("could not update index: %w", err)
+	}
+
+	return nil
+}
+
+func (s *IndexedStorage) read() (map[string]struct{}, error) {
+	data, err := s.upstream.Get(s.key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return map[string]struct{}{}, nil
+		}
+
+		return nil, fmt.Errorf("could not read index: %w", err)
+	}
+
+	var idx index
+
+	if err := json.Unmarshal([]byte(data), &idx); err != nil {
+		return nil, fmt.Errorf("could not read index: %w: %s", errInvalidIndex, err.Error())
+	}
+
+	keys := make(map[string]struct{}, len(idx.Keys))
+
+	for _, k := range idx.Keys {
+		keys[k] = struct{}{}
+	}
+
+	return keys, nil
+}
+
+func (s *IndexedStorage) write(keys map[string]struct{}) error {
+	idx := index{Version: indexVersion, Keys: mapKeys(keys)}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	return s.upstream.Set(s.key, string(data))
+}
+
+func mapKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewIndexedStorage creates a storage that maintains the list of its keys in the DefaultIndexKey entry of the upstream
+// storage.
+//
+// The updates of the index are serialized within the storage. The storages of other processes are only serialized with
+// a shared locker, see WithIndexLocker, otherwise a concurrent update may be lost until the index is repaired.
+func NewIndexedStorage(upstream Storage, options ...IndexedStorageOption) *IndexedStorage {
+	s := &IndexedStorage{
+		upstream: upstream,
+		key:      DefaultIndexKey,
+		logger:   ctxd.NoOpLogger{},
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithIndexKey sets the key of the index entry.
+func WithIndexKey(key string) IndexedStorageOption {
+	return func(s *IndexedStorage) {
+		s.key = key
+	}
+}
+
+// WithIndexLocker sets a lock that is held while updating the index, such as NewFileLocker, so that the storages of
+// several processes sharing the same upstream storage do not lose each other's changes.
+func WithIndexLocker(l Locker) IndexedStorageOption {
+	return func(s *IndexedStorage) {
+		s.locker = l
+	}
+}
+
+// WithIndexLogger sets the logger for the failed updates of the index.
+func WithIndexLogger(logger ctxd.Logger) IndexedStorageOption {
+	return func(s *IndexedStorage) {
+		s.logger = logger
+	}
+}
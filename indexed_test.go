@@ -0,0 +1,250 @@
+package moneyloverkeychain_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+func TestIndexedStorage(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewIndexedStorage(upstream)
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	keys, err := s.List("")
+
+	assert.Empty(t, keys)
+	require.NoError(t, err)
+
+	// Set.
+	require.NoError(t, s.Set("token-b", "b"))
+	require.NoError(t, s.Set("token-a", "a"))
+	require.NoError(t, s.Set("credentials", "c"))
+
+	data, err = s.Get("token-a")
+
+	assert.Equal(t, "a", data)
+	require.NoError(t, err)
+
+	keys, err = s.List("token-")
+
+	assert.Equal(t, []string{"token-a", "token-b"}, keys)
+	require.NoError(t, err)
+
+	data, err = upstream.Get(moneyloverkeychain.DefaultIndexKey)
+
+	assert.Equal(t, `{"version":1,"keys":["credentials","token-a","token-b"]}`, data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete("token-a")
+	require.NoError(t, err)
+
+	err = s.Delete("token-a")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	keys, err = s.List("")
+
+	assert.Equal(t, []string{"credentials", "token-b"}, keys)
+	require.NoError(t, err)
+
+	// The index can not be used as a key.
+	err = s.Set(moneyloverkeychain.DefaultIndexKey, "foobar")
+
+	require.EqualError(t, err, `invalid key: "moneyloverkeychain/index" is reserved for the index`)
+
+	// The index is removed with the last key.
+	require.NoError(t, s.Delete("credentials"))
+	require.NoError(t, s.Delete("token-b"))
+
+	assert.Empty(t, upstream.Snapshot())
+}
+
+// yieldingStorage lets the other goroutines run after reading, so that the concurrent writers interleave.
+type yieldingStorage struct {
+	moneyloverkeychain.Storage
+}
+
+func (s *yieldingStorage) Get(user string) (string, error) {
+	defer time.Sleep(time.Millisecond)
+
+	return s.Storage.Get(user)
+}
+
+func TestIndexedStorage_ConcurrentWriters(t *testing.T) {
+	t.Parallel()
+
+	upstream := &yieldingStorage{Storage: moneyloverkeychain.NewMemoryStorage()}
+	path := filepath.Join(t.TempDir(), "index.lock")
+
+	// Two processes sharing the same upstream storage and lock file.
+	writers := []*moneyloverkeychain.IndexedStorage{
+		moneyloverkeychain.NewIndexedStorage(upstream,
+			moneyloverkeychain.WithIndexLocker(moneyloverkeychain.NewFileLocker(path)),
+		),
+		moneyloverkeychain.NewIndexedStorage(upstream,
+			moneyloverkeychain.WithIndexLocker(moneyloverkeychain.NewFileLocker(path)),
+		),
+	}
+
+	var wg sync.WaitGroup
+
+	expected := make([]string, 0, 20)
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%02d", i)
+		expected = append(expected, key)
+
+		wg.Add(1)
+
+		go func(s *moneyloverkeychain.IndexedStorage) {
+			defer wg.Done()
+
+			assert.NoError(t, s.Set(key, "foobar"))
+		}(writers[i%2])
+	}
+
+	wg.Wait()
+
+	keys, err := writers[0].List("")
+
+	assert.Equal(t, expected, keys)
+	require.NoError(t, err)
+}
+
+func TestIndexedStorage_IndexNotUpdated(t *testing.T) {
+	t.Parallel()
+
+	// The parent of the lock is a file.
+	parent := filepath.Join(t.TempDir(), "file")
+
+	require.NoError(t, os.WriteFile(parent, nil, 0o600))
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	l := &ctxd.LoggerMock{}
+	s := moneyloverkeychain.NewIndexedStorage(upstream,
+		moneyloverkeychain.WithIndexLocker(moneyloverkeychain.NewFileLocker(filepath.Join(parent, "lock"))),
+		moneyloverkeychain.WithIndexLogger(l),
+	)
+
+	// The secret is written even if the index is not.
+	require.NoError(t, s.Set("test", "foobar"))
+
+	assert.Equal(t, map[string]string{"test": "foobar"}, upstream.Snapshot())
+	assert.Contains(t, l.String(), `warn: could not update the index of the storage {"error":{},"key":"test","operation":"set"}`)
+
+	require.NoError(t, s.Delete("test"))
+
+	assert.Empty(t, upstream.Snapshot())
+}
+
+func TestIndexedStorage_Repair(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	upstream.Restore(map[string]string{
+		moneyloverkeychain.DefaultIndexKey: `{"version":1,"keys":["deleted","exists"]}`,
+		"exists":                           "foobar",
+		"unindexed":                        "foobar",
+	})
+
+	s := moneyloverkeychain.NewIndexedStorage(upstream)
+
+	err := s.Repair("unindexed", "unknown")
+	require.NoError(t, err)
+
+	keys, err := s.List("")
+
+	assert.Equal(t, []string{"exists", "unindexed"}, keys)
+	require.NoError(t, err)
+}
+
+func TestIndexedStorage_RepairInvalidIndex(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	upstream.Restore(map[string]string{
+		moneyloverkeychain.DefaultIndexKey: `garbage`,
+		"exists":                           "foobar",
+	})
+
+	l := &ctxd.LoggerMock{}
+	s := moneyloverkeychain.NewIndexedStorage(upstream, moneyloverkeychain.WithIndexLogger(l))
+
+	_, err := s.List("")
+	require.Error(t, err)
+
+	err = s.Repair("exists", "unknown")
+	require.NoError(t, err)
+
+	assert.Contains(t, l.String(), "warn: could not decode the index of the storage, rebuilding it")
+
+	keys, err := s.List("")
+
+	assert.Equal(t, []string{"exists"}, keys)
+	require.NoError(t, err)
+
+	// The index is removed when none of the candidates exists.
+	upstream.Restore(map[string]string{moneyloverkeychain.DefaultIndexKey: `garbage`})
+
+	err = s.Repair("unknown")
+	require.NoError(t, err)
+
+	assert.Empty(t, upstream.Snapshot())
+}
+
+func TestIndexedStorage_Errors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		mockStorage   mock.StorageMocker
+		expectedError string
+	}{
+		{
+			scenario: "could not read index",
+			mockStorage: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Get", moneyloverkeychain.DefaultIndexKey).Return("", errors.New("get error"))
+			}),
+			expectedError: "could not read index: get error",
+		},
+		{
+			scenario: "invalid index",
+			mockStorage: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Get", moneyloverkeychain.DefaultIndexKey).Return("{", nil)
+			}),
+			expectedError: "could not read index: invalid index: unexpected end of JSON input",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			keys, err := moneyloverkeychain.NewIndexedStorage(tc.mockStorage(t)).List("")
+
+			assert.Empty(t, keys)
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package fsutil provides functionalities for reading and writing secret files.
+package fsutil
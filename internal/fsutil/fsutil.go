@@ -0,0 +1,72 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrInsecurePermissions indicates that a file is accessible by group or others.
+var ErrInsecurePermissions = errors.New("file must not be accessible by group or others")
+
+// ReadPrivateFile reads a file and refuses to do so if it is accessible by group or others.
+func ReadPrivateFile(path string) ([]byte, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close() // nolint: errcheck
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("%w: %s has mode %s", ErrInsecurePermissions, path, fi.Mode().Perm())
+	}
+
+	return io.ReadAll(f)
+}
+
+// WriteFileAtomic writes data to a temporary file which is only accessible by the owner, then renames it to path.
+func WriteFileAtomic(path string, data []byte) (err error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = f.Close()           // nolint: errcheck
+			_ = os.Remove(f.Name()) // nolint: errcheck
+		}
+	}()
+
+	if err := f.Chmod(0o600); err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
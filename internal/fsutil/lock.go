@@ -0,0 +1,41 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrLockNotSupported indicates that file locks are not supported on the platform.
+var ErrLockNotSupported = errors.New("file lock is not supported")
+
+// Lock acquires an exclusive lock on a file that is only accessible by the owner, waiting for the other processes to
+// release it. The file and its directory are created if they do not exist, and the file is never removed. The returned
+// function releases the lock.
+func Lock(path string) (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f); err != nil {
+		_ = f.Close() // nolint: errcheck
+
+		return nil, fmt.Errorf("could not lock %s: %w", path, err)
+	}
+
+	return func() error {
+		err := unlockFile(f)
+
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	}, nil
+}
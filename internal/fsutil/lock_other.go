@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package fsutil
+
+import "os"
+
+func lockFile(*os.File) error {
+	return ErrLockNotSupported
+}
+
+func unlockFile(*os.File) error {
+	return ErrLockNotSupported
+}
@@ -0,0 +1,2 @@
+// Package seal provides functionalities for sealing secrets with AES-256-GCM.
+package seal
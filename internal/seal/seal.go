@@ -0,0 +1,79 @@
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ErrInvalidCiphertext indicates that a ciphertext is too short to be opened.
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Seal encrypts and authenticates the plaintext and the additional data with AES-256-GCM. The random nonce is prepended
+// to the ciphertext.
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := NewAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return SealAEAD(aead, plaintext, additionalData)
+}
+
+// Open decrypts and authenticates a ciphertext sealed by Seal.
+func Open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := NewAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenAEAD(aead, ciphertext, additionalData)
+}
+
+// SealAEAD encrypts and authenticates the plaintext and the additional data. The random nonce is prepended to the
+// ciphertext.
+func SealAEAD(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// OpenAEAD decrypts and authenticates a ciphertext sealed by SealAEAD.
+func OpenAEAD(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// AdditionalData returns the additional data that binds a sealed secret to its service and key, so that it can not be
+// moved to another key.
+func AdditionalData(service, key string) []byte {
+	return []byte(service + "\x00" + key)
+}
+
+// NewAESGCM creates an AES-256-GCM AEAD.
+func NewAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// NewXChaCha20Poly1305 creates a XChaCha20-Poly1305 AEAD.
+func NewXChaCha20Poly1305(key []byte) (cipher.AEAD, error) {
+	return chacha20poly1305.NewX(key)
+}
@@ -0,0 +1,2 @@
+// Package keepass provides a storage in a KeePass database.
+package keepass
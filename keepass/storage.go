@@ -0,0 +1,348 @@
+package keepass
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tobischo/gokeepasslib/v3"
+	w "github.com/tobischo/gokeepasslib/v3/wrappers"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/internal/fsutil"
+)
+
+const (
+	fieldTitle    = "Title"
+	fieldPassword = "Password"
+)
+
+var _ moneyloverkeychain.Storage = (*Storage)(nil)
+
+// ErrNoCredentials indicates that there is neither master password nor key file to unlock the database.
+var ErrNoCredentials = errors.New("no master password or key file")
+
+// Option configures Storage.
+type Option func(s *Storage)
+
+// Storage is a storage in a KeePass database. The service is a group at the top level of the database, and each key is
+// an entry of the group with the key as the title and the secret as the password.
+//
+// A new database is created in KDBX 4 format on the first write. When writing an existing database, the unrelated
+// groups and entries are preserved and the previous version of the entry is kept in its history.
+//
+// The writes lock the file with the ".lock" suffix next to the database, so that the processes sharing the database do
+// not lose each other's changes.
+type Storage struct {
+	mu sync.Mutex
+
+	path     string
+	service  string
+	password *string
+	keyFile  string
+}
+
+// Set sets password in the database for user.
+func (s *Storage) Set(user, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := fsutil.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	defer unlock() // nolint: errcheck
+
+	db, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	g := findOrCreateGroup(&db.Content.Root.Groups[0], s.service)
+	now := w.Now()
+
+	if e := findEntry(g, user); e != nil {
+		pushHistory(e, db.Content.Meta.HistoryMaxItems)
+
+		setValue(e, fieldPassword, password, true)
+
+		e.Times.LastModificationTime = &now
+	} else {
+		entry := gokeepasslib.NewEntry()
+
+		setValue(&entry, fieldTitle, user, false)
+		setValue(&entry, fieldPassword, password, true)
+
+		g.Entries = append(g.Entries, entry)
+	}
+
+	return s.write(db)
+}
+
+// Get gets password from the database.
+func (s *Storage) Get(user string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.read()
+	if err != nil {
+		return "", err
+	}
+
+	g := findGroup(&db.Content.Root.Groups[0], s.service)
+	if g == nil {
+		return "", moneyloverkeychain.ErrNotFound
+	}
+
+	e := findEntry(g, user)
+	if e == nil {
+		return "", moneyloverkeychain.ErrNotFound
+	}
+
+	return e.GetPassword(), nil
+}
+
+// Delete deletes secret from the database.
+func (s *Storage) Delete(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := fsutil.Lock(s.lockPath())
+	if err != nil {
+		return err
+	}
+
+	defer unlock() // nolint: errcheck
+
+	db, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	g := findGroup(&db.Content.Root.Groups[0], s.service)
+	if g == nil {
+		return moneyloverkeychain.ErrNotFound
+	}
+
+	for i := range g.Entries {
+		if g.Entries[i].GetTitle() != user {
+			continue
+		}
+
+		now := w.Now()
+
+		db.Content.Root.DeletedObjects = append(db.Content.Root.DeletedObjects, gokeepasslib.DeletedObjectData{
+			UUID:         g.Entries[i].UUID,
+			DeletionTime: &now,
+		})
+
+		g.Entries = append(g.Entries[:i], g.Entries[i+1:]...)
+
+		return s.write(db)
+	}
+
+	return moneyloverkeychain.ErrNotFound
+}
+
+func (s *Storage) credentials() (*gokeepasslib.DBCredentials, error) {
+	switch {
+	case s.password != nil && s.keyFile != "":
+		return gokeepasslib.NewPasswordAndKeyCredentials(*s.password, s.keyFile)
+
+	case s.keyFile != "":
+		return gokeepasslib.NewKeyCredentials(s.keyFile)
+
+	case s.password != nil:
+		return gokeepasslib.NewPasswordCredentials(*s.password), nil
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// read reads and unlocks the database. If the database does not exist, a new one is returned.
+func (s *Storage) read() (*gokeepasslib.Database, error) {
+	credentials, err := s.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Clean(s.path))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		root := gokeepasslib.NewGroup()
+		root.Name = "Root"
+
+		db := gokeepasslib.NewDatabase(gokeepasslib.WithDatabaseKDBXVersion4())
+		db.Credentials = credentials
+		db.Content.Root = &gokeepasslib.RootData{Groups: []gokeepasslib.Group{root}}
+
+		return db, nil
+	}
+
+	db := gokeepasslib.NewDatabase()
+	db.Credentials = credentials
+
+	if err := gokeepasslib.NewDecoder(bytes.NewReader(data)).Decode(db); err != nil {
+		return nil, err
+	}
+
+	if err := db.UnlockProtectedEntries(); err != nil {
+		return nil, err
+	}
+
+	if len(db.Content.Root.Groups) == 0 {
+		db.Content.Root.Groups = append(db.Content.Root.Groups, gokeepasslib.NewGroup())
+	}
+
+	return db, nil
+}
+
+// write locks and writes the database atomically. The random seeds are regenerated, so that the saves do not reuse the
+// same key and nonce.
+func (s *Storage) write(db *gokeepasslib.Database) error {
+	if err := reseed(db); err != nil {
+		return err
+	}
+
+	if err := db.LockProtectedEntries(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	if err := gokeepasslib.NewEncoder(&buf).Encode(db); err != nil {
+		return err
+	}
+
+	return fsutil.WriteFileAtomic(s.path, buf.Bytes())
+}
+
+// lockPath returns the path of the file that is locked while writing.
+func (s *Storage) lockPath() string {
+	return s.path + ".lock"
+}
+
+// reseed regenerates the master seed, the encryption IV and the key of the inner random stream, keeping their sizes.
+// The protected values must be unlocked, because they are locked again with the new stream key.
+func reseed(db *gokeepasslib.Database) error {
+	fh := db.Header.FileHeaders
+	seeds := [][]byte{fh.MasterSeed, fh.EncryptionIV}
+
+	if db.Header.IsKdbx4() {
+		seeds = append(seeds, db.Content.InnerHeader.InnerRandomStreamKey)
+	} else {
+		seeds = append(seeds, fh.ProtectedStreamKey, fh.StreamStartBytes)
+	}
+
+	for _, seed := range seeds {
+		if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func findGroup(parent *gokeepasslib.Group, name string) *gokeepasslib.Group {
+	for i := range parent.Groups {
+		if parent.Groups[i].Name == name {
+			return &parent.Groups[i]
+		}
+	}
+
+	return nil
+}
+
+func findOrCreateGroup(parent *gokeepasslib.Group, name string) *gokeepasslib.Group {
+	if g := findGroup(parent, name); g != nil {
+		return g
+	}
+
+	g := gokeepasslib.NewGroup()
+	g.Name = name
+
+	parent.Groups = append(parent.Groups, g)
+
+	return &parent.Groups[len(parent.Groups)-1]
+}
+
+func findEntry(g *gokeepasslib.Group, title string) *gokeepasslib.Entry {
+	for i := range g.Entries {
+		if g.Entries[i].GetTitle() == title {
+			return &g.Entries[i]
+		}
+	}
+
+	return nil
+}
+
+func setValue(e *gokeepasslib.Entry, key, value string, protected bool) {
+	v := gokeepasslib.V{Content: value, Protected: w.NewBoolWrapper(protected)}
+
+	if i := e.GetIndex(key); i >= 0 {
+		e.Values[i].Value = v
+
+		return
+	}
+
+	e.Values = append(e.Values, gokeepasslib.ValueData{Key: key, Value: v})
+}
+
+// pushHistory saves the current version of the entry to its history, keeping at most maxItems versions. A negative
+// maxItems means unlimited history.
+func pushHistory(e *gokeepasslib.Entry, maxItems int64) {
+	if maxItems == 0 {
+		return
+	}
+
+	snapshot := *e
+	snapshot.Values = append([]gokeepasslib.ValueData(nil), e.Values...)
+	snapshot.Histories = nil
+
+	if len(e.Histories) == 0 {
+		e.Histories = []gokeepasslib.History{{}}
+	}
+
+	h := &e.Histories[0]
+	h.Entries = append(h.Entries, snapshot)
+
+	if maxItems > 0 && int64(len(h.Entries)) > maxItems {
+		h.Entries = h.Entries[int64(len(h.Entries))-maxItems:]
+	}
+}
+
+// New creates a storage in a KeePass database.
+func New(path, service string, options ...Option) *Storage {
+	s := &Storage{
+		path:    path,
+		service: service,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithPassword sets the master password of the database.
+func WithPassword(password string) Option {
+	return func(s *Storage) {
+		s.password = &password
+	}
+}
+
+// WithKeyFile sets the key file of the database.
+func WithKeyFile(path string) Option {
+	return func(s *Storage) {
+		s.keyFile = path
+	}
+}
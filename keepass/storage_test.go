@@ -0,0 +1,264 @@
+package keepass_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tobischo/gokeepasslib/v3"
+	w "github.com/tobischo/gokeepasslib/v3/wrappers"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/keepass"
+)
+
+func value(key, value string, protected bool) gokeepasslib.ValueData {
+	return gokeepasslib.ValueData{Key: key, Value: gokeepasslib.V{Content: value, Protected: w.NewBoolWrapper(protected)}}
+}
+
+func readDatabase(t *testing.T, path, password string) *gokeepasslib.Database {
+	t.Helper()
+
+	f, err := os.Open(filepath.Clean(path))
+	require.NoError(t, err)
+
+	defer f.Close() // nolint: errcheck
+
+	db := gokeepasslib.NewDatabase()
+	db.Credentials = gokeepasslib.NewPasswordCredentials(password)
+
+	require.NoError(t, gokeepasslib.NewDecoder(f).Decode(db))
+	require.NoError(t, db.UnlockProtectedEntries())
+
+	return db
+}
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "db.kdbx")
+	s := keepass.New(path, "moneyloverapi.credentials", keepass.WithPassword("secret"))
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	db := readDatabase(t, path, "secret")
+
+	assert.True(t, db.Header.IsKdbx4())
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestStorage_PreserveEntriesAndHistory(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "db.kdbx")
+
+	// Prepare a database with unrelated entries.
+	root := gokeepasslib.NewGroup()
+	root.Name = "Passwords"
+
+	entry := gokeepasslib.NewEntry()
+	entry.Values = append(entry.Values, value("Title", "GMail", false), value("Password", "hunter2", true))
+
+	root.Entries = append(root.Entries, entry)
+
+	db := gokeepasslib.NewDatabase(gokeepasslib.WithDatabaseKDBXVersion4())
+	db.Credentials = gokeepasslib.NewPasswordCredentials("secret")
+	db.Content.Root = &gokeepasslib.RootData{Groups: []gokeepasslib.Group{root}}
+
+	require.NoError(t, db.LockProtectedEntries())
+
+	f, err := os.Create(filepath.Clean(path))
+	require.NoError(t, err)
+	require.NoError(t, gokeepasslib.NewEncoder(f).Encode(db))
+	require.NoError(t, f.Close())
+
+	// Test.
+	s := keepass.New(path, "moneyloverapi.credentials", keepass.WithPassword("secret"))
+
+	require.NoError(t, s.Set("test", "foobar"))
+	require.NoError(t, s.Set("test", "foobaz"))
+
+	// Verify.
+	db = readDatabase(t, path, "secret")
+	root = db.Content.Root.Groups[0]
+
+	require.Len(t, root.Entries, 1)
+	assert.Equal(t, "GMail", root.Entries[0].GetTitle())
+	assert.Equal(t, "hunter2", root.Entries[0].GetPassword())
+
+	require.Len(t, root.Groups, 1)
+	assert.Equal(t, "moneyloverapi.credentials", root.Groups[0].Name)
+
+	require.Len(t, root.Groups[0].Entries, 1)
+
+	entry = root.Groups[0].Entries[0]
+
+	assert.Equal(t, "test", entry.GetTitle())
+	assert.Equal(t, "foobaz", entry.GetPassword())
+
+	require.Len(t, entry.Histories, 1)
+	require.Len(t, entry.Histories[0].Entries, 1)
+	assert.Equal(t, "foobar", entry.Histories[0].Entries[0].GetPassword())
+}
+
+func TestStorage_RegenerateSeeds(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		version  gokeepasslib.DatabaseOption
+	}{
+		{
+			scenario: "kdbx 3.1",
+			version:  gokeepasslib.WithDatabaseKDBXVersion3(),
+		},
+		{
+			scenario: "kdbx 4",
+			version:  gokeepasslib.WithDatabaseKDBXVersion4(),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "db.kdbx")
+
+			db := gokeepasslib.NewDatabase(tc.version)
+			db.Credentials = gokeepasslib.NewPasswordCredentials("secret")
+			db.Content.Root = &gokeepasslib.RootData{Groups: []gokeepasslib.Group{gokeepasslib.NewGroup()}}
+
+			require.NoError(t, db.LockProtectedEntries())
+
+			f, err := os.Create(filepath.Clean(path))
+			require.NoError(t, err)
+			require.NoError(t, gokeepasslib.NewEncoder(f).Encode(db))
+			require.NoError(t, f.Close())
+
+			s := keepass.New(path, "moneyloverapi.credentials", keepass.WithPassword("secret"))
+
+			require.NoError(t, s.Set("test", "foobar"))
+
+			first := readDatabase(t, path, "secret")
+
+			require.NoError(t, s.Set("test", "foobaz"))
+
+			second := readDatabase(t, path, "secret")
+
+			assert.NotEqual(t, first.Header.FileHeaders.MasterSeed, second.Header.FileHeaders.MasterSeed)
+			assert.NotEqual(t, first.Header.FileHeaders.EncryptionIV, second.Header.FileHeaders.EncryptionIV)
+
+			if second.Header.IsKdbx4() {
+				assert.NotEqual(t, first.Content.InnerHeader.InnerRandomStreamKey, second.Content.InnerHeader.InnerRandomStreamKey)
+			} else {
+				assert.NotEqual(t, first.Header.FileHeaders.ProtectedStreamKey, second.Header.FileHeaders.ProtectedStreamKey)
+				assert.NotEqual(t, first.Header.FileHeaders.StreamStartBytes, second.Header.FileHeaders.StreamStartBytes)
+			}
+
+			// The protected values are readable with the new stream key.
+			entry := second.Content.Root.Groups[0].Groups[0].Entries[0]
+
+			assert.Equal(t, "foobaz", entry.GetPassword())
+			assert.Equal(t, "foobar", entry.Histories[0].Entries[0].GetPassword())
+		})
+	}
+}
+
+func TestStorage_ConcurrentProcesses(t *testing.T) {
+	t.Parallel()
+
+	const writers = 4
+
+	path := filepath.Join(t.TempDir(), "db.kdbx")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		// Every writer has its own storage, like separate processes.
+		s := keepass.New(path, "moneyloverapi.credentials", keepass.WithPassword("secret"))
+		key := fmt.Sprintf("key%d", i)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, s.Set(key, "value"))
+		}()
+	}
+
+	wg.Wait()
+
+	s := keepass.New(path, "moneyloverapi.credentials", keepass.WithPassword("secret"))
+
+	for i := 0; i < writers; i++ {
+		data, err := s.Get(fmt.Sprintf("key%d", i))
+
+		assert.Equal(t, "value", data)
+		assert.NoError(t, err)
+	}
+}
+
+func TestStorage_KeyFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.kdbx")
+	keyFile := filepath.Join(dir, "db.key")
+
+	err := os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0o600)
+	require.NoError(t, err)
+
+	err = keepass.New(path, "moneyloverapi.token", keepass.WithPassword("secret"), keepass.WithKeyFile(keyFile)).
+		Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err := keepass.New(path, "moneyloverapi.token", keepass.WithPassword("secret"), keepass.WithKeyFile(keyFile)).
+		Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Without the key file.
+	_, err = keepass.New(path, "moneyloverapi.token", keepass.WithPassword("secret")).Get("test")
+
+	require.EqualError(t, err, "Wrong password? HMAC-SHA256 of header mismatching")
+}
+
+func TestStorage_NoCredentials(t *testing.T) {
+	t.Parallel()
+
+	_, err := keepass.New(filepath.Join(t.TempDir(), "db.kdbx"), "moneyloverapi.token").Get("test")
+
+	assert.ErrorIs(t, err, keepass.ErrNoCredentials)
+}
@@ -0,0 +1,96 @@
+package moneyloverkeychain
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/nhatthm/moneyloverkeychain/internal/fsutil"
+)
+
+const encryptionKeyLength = 32
+
+// KeySource provides a 256-bit encryption key.
+type KeySource func() ([]byte, error)
+
+// CachedKey loads the key from the source on the first use and keeps it. Only a loaded key is kept, so a key file or a
+// variable that is not available yet is read again on the next use.
+func CachedKey(source KeySource) KeySource {
+	var (
+		mu  sync.Mutex
+		key []byte
+	)
+
+	return func() ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if key == nil {
+			k, err := source()
+			if err != nil {
+				return nil, err
+			}
+
+			key = k
+		}
+
+		return key, nil
+	}
+}
+
+// StaticKey provides a fixed encryption key.
+func StaticKey(key []byte) KeySource {
+	return func() ([]byte, error) {
+		if len(key) != encryptionKeyLength {
+			return nil, ErrInvalidEncryptionKey
+		}
+
+		return key, nil
+	}
+}
+
+// KeyFromFile reads the encryption key from a file, which is either raw, hex or base64 encoded. The file must not be
+// accessible by group or others.
+func KeyFromFile(path string) KeySource {
+	return func() ([]byte, error) {
+		data, err := fsutil.ReadPrivateFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data) == encryptionKeyLength {
+			return data, nil
+		}
+
+		return decodeEncryptionKey(string(data))
+	}
+}
+
+// KeyFromEnv reads the hex or base64 encoded encryption key from an environment variable.
+func KeyFromEnv(name string) KeySource {
+	return func() ([]byte, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not set", ErrInvalidEncryptionKey, name)
+		}
+
+		return decodeEncryptionKey(value)
+	}
+}
+
+func decodeEncryptionKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+
+	if key, err := hex.DecodeString(s); err == nil && len(key) == encryptionKeyLength {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == encryptionKeyLength {
+		return key, nil
+	}
+
+	return nil, ErrInvalidEncryptionKey
+}
@@ -0,0 +1,134 @@
+package moneyloverkeychain_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+var testEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestStaticKey(t *testing.T) {
+	t.Parallel()
+
+	key, err := moneyloverkeychain.StaticKey(testEncryptionKey)()
+
+	assert.Equal(t, testEncryptionKey, key)
+	require.NoError(t, err)
+
+	key, err = moneyloverkeychain.StaticKey([]byte("short"))()
+
+	assert.Nil(t, key)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrInvalidEncryptionKey)
+}
+
+func TestCachedKey(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	key := moneyloverkeychain.CachedKey(func() ([]byte, error) {
+		calls++
+
+		if calls == 1 {
+			return nil, os.ErrNotExist
+		}
+
+		return testEncryptionKey, nil
+	})
+
+	// The error is not kept.
+	_, err := key()
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	for i := 0; i < 2; i++ {
+		data, err := key()
+
+		assert.Equal(t, testEncryptionKey, data)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestKeyFromFile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		content       []byte
+		mode          os.FileMode
+		expectedError error
+	}{
+		{
+			scenario: "raw",
+			content:  testEncryptionKey,
+			mode:     0o600,
+		},
+		{
+			scenario: "hex",
+			content:  []byte(hex.EncodeToString(testEncryptionKey) + "\n"),
+			mode:     0o600,
+		},
+		{
+			scenario: "base64",
+			content:  []byte(base64.StdEncoding.EncodeToString(testEncryptionKey) + "\n"),
+			mode:     0o400,
+		},
+		{
+			scenario:      "invalid",
+			content:       []byte("short"),
+			mode:          0o600,
+			expectedError: moneyloverkeychain.ErrInvalidEncryptionKey,
+		},
+		{
+			scenario:      "insecure permissions",
+			content:       testEncryptionKey,
+			mode:          0o640,
+			expectedError: moneyloverkeychain.ErrInsecurePermissions,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "key")
+
+			require.NoError(t, os.WriteFile(path, tc.content, tc.mode))
+			require.NoError(t, os.Chmod(path, tc.mode))
+
+			key, err := moneyloverkeychain.KeyFromFile(path)()
+
+			if tc.expectedError == nil {
+				assert.Equal(t, testEncryptionKey, key)
+				require.NoError(t, err)
+			} else {
+				assert.Nil(t, key)
+				assert.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestKeyFromEnv(t *testing.T) {
+	t.Setenv("MONEYLOVER_KEY", hex.EncodeToString(testEncryptionKey))
+
+	key, err := moneyloverkeychain.KeyFromEnv("MONEYLOVER_KEY")()
+
+	assert.Equal(t, testEncryptionKey, key)
+	require.NoError(t, err)
+
+	key, err = moneyloverkeychain.KeyFromEnv("MONEYLOVER_UNKNOWN_KEY")()
+
+	assert.Nil(t, key)
+	require.EqualError(t, err, "encryption key must be 32 bytes, hex or base64 encoded: MONEYLOVER_UNKNOWN_KEY is not set")
+}
@@ -0,0 +1,2 @@
+// Package keyctl provides a storage in the Linux kernel key retention service.
+package keyctl
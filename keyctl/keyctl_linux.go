@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package keyctl
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+const (
+	keyType = "user"
+
+	// The possessor and the owner can view, read, write, search, link and set attributes of the key. Without the owner
+	// permissions, a key in the user keyring can not be read by a process that does not possess it.
+	keyPerm = 0x3f3f0000
+)
+
+func setKey(keyring Keyring, description string, payload []byte, timeout time.Duration) error {
+	id, err := unix.AddKey(keyType, description, payload, int(keyring))
+	if err != nil {
+		return err
+	}
+
+	if err := unix.KeyctlSetperm(id, keyPerm); err != nil {
+		return err
+	}
+
+	if timeout <= 0 {
+		return nil
+	}
+
+	seconds := int(math.Ceil(timeout.Seconds()))
+
+	_, err = unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, id, seconds, 0, 0)
+
+	return err
+}
+
+func getKey(keyring Keyring, description string) ([]byte, error) {
+	id, err := searchKey(keyring, description)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, nil, 0)
+		if err != nil {
+			return nil, keyError(err)
+		}
+
+		buf := make([]byte, size)
+
+		n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+		if err != nil {
+			return nil, keyError(err)
+		}
+
+		// The key was updated between the two reads.
+		if n > size {
+			continue
+		}
+
+		return buf[:n], nil
+	}
+}
+
+func deleteKey(keyring Keyring, description string) error {
+	id, err := searchKey(keyring, description)
+	if err != nil {
+		return err
+	}
+
+	if _, err := unix.KeyctlInt(unix.KEYCTL_UNLINK, id, int(keyring), 0, 0); err != nil {
+		return keyError(err)
+	}
+
+	return nil
+}
+
+func searchKey(keyring Keyring, description string) (int, error) {
+	id, err := unix.KeyctlSearch(int(keyring), keyType, description, 0)
+	if err != nil {
+		return 0, keyError(err)
+	}
+
+	return id, nil
+}
+
+// keyError converts the errors of missing, expired or revoked keys to moneyloverkeychain.ErrNotFound.
+func keyError(err error) error {
+	if errors.Is(err, unix.ENOKEY) || errors.Is(err, unix.EKEYEXPIRED) || errors.Is(err, unix.EKEYREVOKED) {
+		return moneyloverkeychain.ErrNotFound
+	}
+
+	return err
+}
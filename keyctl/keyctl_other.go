@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package keyctl
+
+import "time"
+
+func setKey(Keyring, string, []byte, time.Duration) error {
+	return ErrNotSupported
+}
+
+func getKey(Keyring, string) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func deleteKey(Keyring, string) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,95 @@
+package keyctl
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+// Keyring is a keyring ID, either a special keyring or the serial number of a keyring.
+type Keyring int
+
+const (
+	// ProcessKeyring is the keyring of the current process. It is created on demand for the calling thread, so it
+	// should be created before the goroutines run on several threads.
+	ProcessKeyring Keyring = -2
+	// SessionKeyring is the keyring of the current session.
+	SessionKeyring Keyring = -3
+	// UserKeyring is the keyring of the current user.
+	UserKeyring Keyring = -4
+)
+
+var _ moneyloverkeychain.Storage = (*Storage)(nil)
+
+// ErrNotSupported indicates that the kernel key retention service is not supported on the current platform.
+var ErrNotSupported = errors.New("kernel keyring is not supported")
+
+// Option configures Storage.
+type Option func(s *Storage)
+
+// Storage is a storage in the Linux kernel key retention service. The secrets are stored as "user" keys, described by
+// the service and the user.
+type Storage struct {
+	service string
+	keyring Keyring
+	timeout time.Duration
+}
+
+// Set sets password in the kernel keyring for user.
+func (s *Storage) Set(user, password string) error {
+	return s.SetWithTimeout(user, password, s.timeout)
+}
+
+// SetWithTimeout sets password in the kernel keyring for user. The key expires after the timeout, zero means no
+// expiry.
+func (s *Storage) SetWithTimeout(user, password string, timeout time.Duration) error {
+	return setKey(s.keyring, s.description(user), []byte(password), timeout)
+}
+
+// Get gets password from the kernel keyring.
+func (s *Storage) Get(user string) (string, error) {
+	data, err := getKey(s.keyring, s.description(user))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// Delete deletes secret from the kernel keyring.
+func (s *Storage) Delete(user string) error {
+	return deleteKey(s.keyring, s.description(user))
+}
+
+func (s *Storage) description(user string) string {
+	return s.service + ":" + user
+}
+
+// New creates a storage in the user keyring.
+func New(service string, options ...Option) *Storage {
+	s := &Storage{
+		service: service,
+		keyring: UserKeyring,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithKeyring sets the keyring of the storage.
+func WithKeyring(keyring Keyring) Option {
+	return func(s *Storage) {
+		s.keyring = keyring
+	}
+}
+
+// WithTimeout sets the default timeout of the keys, zero means no expiry.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Storage) {
+		s.timeout = timeout
+	}
+}
@@ -0,0 +1,117 @@
+//go:build linux
+// +build linux
+
+package keyctl_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/keyctl"
+)
+
+func newStorage(t *testing.T, options ...keyctl.Option) *keyctl.Storage {
+	t.Helper()
+
+	// The process keyring is attached to the credentials of the thread that creates it, so a dedicated keyring in the
+	// user keyring is used to be independent of the threads running the goroutines.
+	name := "moneyloverkeychain.test." + uuid.NewString()
+
+	id, err := unix.AddKey("keyring", name, nil, int(keyctl.UserKeyring))
+	if err != nil {
+		if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) || errors.Is(err, unix.EACCES) {
+			t.Skipf("kernel keyring is not available: %s", err.Error())
+		}
+
+		require.NoError(t, err)
+	}
+
+	t.Cleanup(func() {
+		_, _ = unix.KeyctlInt(unix.KEYCTL_UNLINK, id, int(keyctl.UserKeyring), 0, 0) // nolint: errcheck
+	})
+
+	options = append([]keyctl.Option{keyctl.WithKeyring(keyctl.Keyring(id))}, options...)
+
+	return keyctl.New(name, options...)
+}
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	s := newStorage(t)
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Update.
+	err = s.Set("test", "foobaz")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestStorage_Timeout(t *testing.T) {
+	t.Parallel()
+
+	// The expiry has a granularity of one second, a shorter timeout could expire right away.
+	s := newStorage(t, keyctl.WithTimeout(2*time.Second))
+
+	err := s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	err = s.SetWithTimeout("forever", "foobar", 0)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err := s.Get("test")
+
+		return errors.Is(err, moneyloverkeychain.ErrNotFound)
+	}, 5*time.Second, 100*time.Millisecond)
+
+	data, err = s.Get("forever")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Delete("forever"))
+}
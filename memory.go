@@ -0,0 +1,110 @@
+package moneyloverkeychain
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	_ Storage = (*MemoryStorage)(nil)
+	_ Lister  = (*MemoryStorage)(nil)
+)
+
+// MemoryStorage is a concurrency-safe storage in memory. The zero value is an empty storage ready to use.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	secrets map[string]string
+}
+
+// Set sets password in memory for user.
+func (s *MemoryStorage) Set(user, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.secrets == nil {
+		s.secrets = make(map[string]string)
+	}
+
+	s.secrets[user] = password
+
+	return nil
+}
+
+// Get gets password from memory.
+func (s *MemoryStorage) Get(user string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	password, ok := s.secrets[user]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return password, nil
+}
+
+// Delete deletes secret from memory.
+func (s *MemoryStorage) Delete(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.secrets[user]; !ok {
+		return ErrNotFound
+	}
+
+	delete(s.secrets, user)
+
+	return nil
+}
+
+// List lists the keys that start with the prefix.
+func (s *MemoryStorage) List(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.secrets))
+
+	for k := range s.secrets {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Snapshot returns a copy of all the secrets.
+func (s *MemoryStorage) Snapshot() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return copySecrets(s.secrets)
+}
+
+// Restore replaces all the secrets with a copy of the snapshot.
+func (s *MemoryStorage) Restore(snapshot map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.secrets = copySecrets(snapshot)
+}
+
+func copySecrets(secrets map[string]string) map[string]string {
+	result := make(map[string]string, len(secrets))
+
+	for k, v := range secrets {
+		result[k] = v
+	}
+
+	return result
+}
+
+// NewMemoryStorage creates a storage in memory.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		secrets: make(map[string]string),
+	}
+}
@@ -0,0 +1,137 @@
+package moneyloverkeychain_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewMemoryStorage()
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestMemoryStorage_ZeroValue(t *testing.T) {
+	t.Parallel()
+
+	var s moneyloverkeychain.MemoryStorage
+
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"test": "foobar"}, s.Snapshot())
+}
+
+func TestMemoryStorage_SnapshotAndRestore(t *testing.T) {
+	t.Parallel()
+
+	fixtures := map[string]string{"foo": "bar"}
+
+	s := moneyloverkeychain.NewMemoryStorage()
+	s.Restore(fixtures)
+
+	// The fixtures are copied.
+	fixtures["foo"] = "baz"
+
+	data, err := s.Get("foo")
+
+	assert.Equal(t, "bar", data)
+	require.NoError(t, err)
+
+	snapshot := s.Snapshot()
+
+	require.NoError(t, s.Set("foo", "qux"))
+	require.NoError(t, s.Set("quux", "corge"))
+
+	assert.Equal(t, map[string]string{"foo": "bar"}, snapshot)
+	assert.Equal(t, map[string]string{"foo": "qux", "quux": "corge"}, s.Snapshot())
+
+	s.Restore(snapshot)
+
+	assert.Equal(t, map[string]string{"foo": "bar"}, s.Snapshot())
+}
+
+func TestMemoryStorage_List(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewMemoryStorage()
+	s.Restore(map[string]string{"token-b": "b", "token-a": "a", "credentials": "c"})
+
+	keys, err := s.List("token-")
+
+	assert.Equal(t, []string{"token-a", "token-b"}, keys)
+	require.NoError(t, err)
+
+	keys, err = s.List("")
+
+	assert.Equal(t, []string{"credentials", "token-a", "token-b"}, keys)
+	require.NoError(t, err)
+}
+
+func TestMemoryStorage_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewMemoryStorage()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			key := fmt.Sprintf("key-%d", i)
+
+			assert.NoError(t, s.Set(key, "value"))
+
+			_, err := s.Get(key)
+			assert.NoError(t, err)
+
+			_ = s.Snapshot()
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Len(t, s.Snapshot(), 10)
+}
@@ -0,0 +1,127 @@
+package moneyloverkeychain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultNamespaceSeparator separates the namespaces and the key.
+const DefaultNamespaceSeparator = "/"
+
+var _ Storage = (*NamespacedStorage)(nil)
+
+// ErrOutsideNamespace indicates that a key is not in the namespace of a strict namespaced storage.
+var ErrOutsideNamespace = errors.New("key is outside the namespace")
+
+// NamespacedStorageOption configures NamespacedStorage.
+type NamespacedStorageOption func(s *NamespacedStorage)
+
+// NamespacedStorage is a storage that scopes the keys by a namespace, such as an environment or a tenant.
+type NamespacedStorage struct {
+	upstream  Storage
+	namespace string
+	separator string
+	strict    bool
+}
+
+// Set sets password in the namespace for user.
+func (s *NamespacedStorage) Set(user, password string) error {
+	key, err := s.key(user)
+	if err != nil {
+		return err
+	}
+
+	return s.wrapError(s.upstream.Set(key, password))
+}
+
+// Get gets password from the namespace.
+func (s *NamespacedStorage) Get(user string) (string, error) {
+	key, err := s.key(user)
+	if err != nil {
+		return "", err
+	}
+
+	password, err := s.upstream.Get(key)
+
+	return password, s.wrapError(err)
+}
+
+// Delete deletes secret from the namespace.
+func (s *NamespacedStorage) Delete(user string) error {
+	key, err := s.key(user)
+	if err != nil {
+		return err
+	}
+
+	return s.wrapError(s.upstream.Delete(key))
+}
+
+// Namespace returns the full namespace, including the parent namespaces.
+func (s *NamespacedStorage) Namespace() string {
+	return s.namespace
+}
+
+// Child creates a storage in a child namespace.
+func (s *NamespacedStorage) Child(namespace string) *NamespacedStorage {
+	c := *s
+	c.namespace = s.namespace + s.separator + namespace
+
+	return &c
+}
+
+func (s *NamespacedStorage) key(user string) (string, error) {
+	if s.strict && (user == "" || user == "." || user == ".." || strings.Contains(user, s.separator)) {
+		return "", fmt.Errorf("%w: %q is not in namespace %q", ErrOutsideNamespace, user, s.namespace)
+	}
+
+	return s.namespace + s.separator + user, nil
+}
+
+func (s *NamespacedStorage) wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("namespace %q: %w", s.namespace, err)
+}
+
+// NewNamespacedStorage creates a storage that prefixes the keys with the namespace and DefaultNamespaceSeparator. If
+// the upstream storage is a NamespacedStorage, the namespace is a child of its namespace.
+func NewNamespacedStorage(upstream Storage, namespace string, options ...NamespacedStorageOption) *NamespacedStorage {
+	s := &NamespacedStorage{
+		upstream:  upstream,
+		namespace: namespace,
+		separator: DefaultNamespaceSeparator,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	if parent, ok := upstream.(*NamespacedStorage); ok {
+		s.upstream = parent.upstream
+		s.namespace = parent.namespace + parent.separator + namespace
+		s.separator = parent.separator
+		s.strict = s.strict || parent.strict
+	}
+
+	return s
+}
+
+// WithNamespaceSeparator sets the separator between the namespaces and the key.
+func WithNamespaceSeparator(separator string) NamespacedStorageOption {
+	return func(s *NamespacedStorage) {
+		if separator != "" {
+			s.separator = separator
+		}
+	}
+}
+
+// WithStrictNamespace rejects the keys that could address another namespace, the empty keys and the keys containing the
+// separator, with ErrOutsideNamespace.
+func WithStrictNamespace() NamespacedStorageOption {
+	return func(s *NamespacedStorage) {
+		s.strict = true
+	}
+}
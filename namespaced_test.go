@@ -0,0 +1,148 @@
+package moneyloverkeychain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+func TestNamespacedStorage(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	prod := moneyloverkeychain.NewNamespacedStorage(upstream, "prod")
+	staging := moneyloverkeychain.NewNamespacedStorage(upstream, "staging")
+
+	// Get not found.
+	data, err := prod.Get("test")
+
+	assert.Empty(t, data)
+	require.EqualError(t, err, `namespace "prod": secret not found in keyring`)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	require.NoError(t, prod.Set("test", "production"))
+	require.NoError(t, staging.Set("test", "staging"))
+
+	data, err = prod.Get("test")
+
+	assert.Equal(t, "production", data)
+	require.NoError(t, err)
+
+	data, err = staging.Get("test")
+
+	assert.Equal(t, "staging", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"prod/test": "production", "staging/test": "staging"}, upstream.Snapshot())
+
+	// Delete.
+	err = prod.Delete("test")
+	require.NoError(t, err)
+
+	data, err = prod.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = prod.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	assert.Equal(t, map[string]string{"staging/test": "staging"}, upstream.Snapshot())
+}
+
+func TestNamespacedStorage_Composable(t *testing.T) {
+	t.Parallel()
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	prod := moneyloverkeychain.NewNamespacedStorage(upstream, "prod",
+		moneyloverkeychain.WithNamespaceSeparator(":"),
+		moneyloverkeychain.WithStrictNamespace(),
+	)
+	tenant := moneyloverkeychain.NewNamespacedStorage(prod, "tenant-1")
+	child := prod.Child("tenant-2")
+
+	assert.Equal(t, "prod:tenant-1", tenant.Namespace())
+	assert.Equal(t, "prod:tenant-2", child.Namespace())
+
+	require.NoError(t, tenant.Set("test", "tenant-1"))
+	require.NoError(t, child.Set("test", "tenant-2"))
+
+	assert.Equal(t, map[string]string{"prod:tenant-1:test": "tenant-1", "prod:tenant-2:test": "tenant-2"}, upstream.Snapshot())
+
+	// The strict mode is inherited.
+	err := tenant.Set("tenant-2:test", "foobar")
+
+	require.EqualError(t, err, `key is outside the namespace: "tenant-2:test" is not in namespace "prod:tenant-1"`)
+}
+
+func TestNamespacedStorage_Strict(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario      string
+		key           string
+		strict        bool
+		expectedError error
+	}{
+		{
+			scenario: "not strict",
+			key:      "../staging/test",
+		},
+		{
+			scenario:      "separator",
+			key:           "../staging/test",
+			strict:        true,
+			expectedError: moneyloverkeychain.ErrOutsideNamespace,
+		},
+		{
+			scenario:      "empty",
+			key:           "",
+			strict:        true,
+			expectedError: moneyloverkeychain.ErrOutsideNamespace,
+		},
+		{
+			scenario:      "parent",
+			key:           "..",
+			strict:        true,
+			expectedError: moneyloverkeychain.ErrOutsideNamespace,
+		},
+		{
+			scenario: "in namespace",
+			key:      "test",
+			strict:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var options []moneyloverkeychain.NamespacedStorageOption
+
+			if tc.strict {
+				options = append(options, moneyloverkeychain.WithStrictNamespace())
+			}
+
+			s := moneyloverkeychain.NewNamespacedStorage(moneyloverkeychain.NewMemoryStorage(), "prod", options...)
+
+			err := s.Set(tc.key, "foobar")
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.expectedError)
+				assert.ErrorIs(t, s.Delete(tc.key), tc.expectedError)
+
+				_, err := s.Get(tc.key)
+
+				assert.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package pass provides a storage in a pass-compatible password store.
+package pass
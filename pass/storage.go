@@ -0,0 +1,262 @@
+package pass
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/internal/fsutil"
+)
+
+const (
+	gpgIDFile = ".gpg-id"
+	extension = ".gpg"
+)
+
+var _ moneyloverkeychain.Storage = (*Storage)(nil)
+
+// ErrNoGPGID indicates that there is no .gpg-id file for an entry.
+var ErrNoGPGID = errors.New("no .gpg-id file found")
+
+// ErrInvalidKey indicates that a key would point outside the password store.
+var ErrInvalidKey = errors.New("invalid key")
+
+// Option configures Storage.
+type Option func(s *Storage)
+
+// Storage is a storage in a pass-compatible password store. The secret of a key is stored in
+// <store>/<service>/<key>.gpg and is encrypted with gpg to the ids in the nearest .gpg-id file.
+//
+// If the password store is a git repository, every change is committed like pass does.
+type Storage struct {
+	dir     string
+	service string
+	gpg     string
+	git     string
+}
+
+// Set sets password in the password store for user.
+func (s *Storage) Set(user, password string) error {
+	path, err := s.path(user)
+	if err != nil {
+		return err
+	}
+
+	ids, err := s.gpgIDs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	args := []string{"--batch", "--quiet", "--yes", "--compress-algo=none", "--no-encrypt-to", "--encrypt"}
+
+	for _, id := range ids {
+		args = append(args, "--recipient", id)
+	}
+
+	// pass stores the password with a trailing new line.
+	data, err := run(s.gpg, strings.NewReader(password+"\n"), args...)
+	if err != nil {
+		return fmt.Errorf("could not encrypt password: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	if err := fsutil.WriteFileAtomic(path, data); err != nil {
+		return err
+	}
+
+	return s.commit(fmt.Sprintf("Add given password for %s to store.", s.name(user)), path)
+}
+
+// Get gets password from the password store.
+func (s *Storage) Get(user string) (string, error) {
+	path, err := s.path(user)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", moneyloverkeychain.ErrNotFound
+		}
+
+		return "", err
+	}
+
+	data, err := run(s.gpg, nil, "--batch", "--quiet", "--yes", "--decrypt", path)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt password: %w", err)
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// Delete deletes secret from the password store.
+func (s *Storage) Delete(user string) error {
+	path, err := s.path(user)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return moneyloverkeychain.ErrNotFound
+		}
+
+		return err
+	}
+
+	// Like pass, remove the empty directories up to the store.
+	for dir := filepath.Dir(path); dir != s.dir && strings.HasPrefix(dir, s.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return s.commit(fmt.Sprintf("Remove %s from store.", s.name(user)), path)
+}
+
+func (s *Storage) name(user string) string {
+	return filepath.ToSlash(filepath.Join(s.service, user))
+}
+
+// path returns the path of the entry of the user, which must stay in the directory of the service so that a key can not
+// reach the entries of another service.
+func (s *Storage) path(user string) (string, error) {
+	path := filepath.Join(s.dir, s.service, user+extension)
+
+	if !strings.HasPrefix(path, filepath.Join(s.dir, s.service)+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, user)
+	}
+
+	return path, nil
+}
+
+// gpgIDs reads the ids from the nearest .gpg-id file, starting from dir up to the store.
+func (s *Storage) gpgIDs(dir string) ([]string, error) {
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, gpgIDFile)) // nolint: gosec
+		if err == nil {
+			return parseGPGIDs(data), nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		if dir == s.dir || !strings.HasPrefix(dir, s.dir) {
+			return nil, fmt.Errorf("%w in %s", ErrNoGPGID, s.dir)
+		}
+
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (s *Storage) commit(message string, path string) error {
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); err != nil {
+		return nil // nolint: nilerr
+	}
+
+	if _, err := run(s.git, nil, "-C", s.dir, "add", "--all", "--", path); err != nil {
+		return fmt.Errorf("could not add to git: %w", err)
+	}
+
+	if _, err := run(s.git, nil, "-C", s.dir, "commit", "--quiet", "-m", message, "--", path); err != nil {
+		return fmt.Errorf("could not commit to git: %w", err)
+	}
+
+	return nil
+}
+
+func parseGPGIDs(data []byte) []string {
+	var ids []string
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+
+	for sc.Scan() {
+		line := sc.Text()
+
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		if line = strings.TrimSpace(line); line != "" {
+			ids = append(ids, line)
+		}
+	}
+
+	return ids
+}
+
+func run(name string, stdin *strings.Reader, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(name, args...) // nolint: gosec
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// New creates a storage in the password store at $PASSWORD_STORE_DIR, or ~/.password-store if it is not set.
+func New(service string, options ...Option) *Storage {
+	dir := os.Getenv("PASSWORD_STORE_DIR")
+
+	if dir == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".password-store")
+		}
+	}
+
+	s := &Storage{
+		service: service,
+		gpg:     "gpg",
+		git:     "git",
+	}
+
+	WithStoreDir(dir)(s)
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithStoreDir sets the directory of the password store.
+func WithStoreDir(dir string) Option {
+	return func(s *Storage) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+
+		s.dir = filepath.Clean(dir)
+	}
+}
+
+// WithGPG sets the gpg binary.
+func WithGPG(gpg string) Option {
+	return func(s *Storage) {
+		s.gpg = gpg
+	}
+}
@@ -0,0 +1,204 @@
+package pass_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/pass"
+)
+
+func runCommand(t *testing.T, name string, args ...string) string {
+	t.Helper()
+
+	out, err := exec.Command(name, args...).CombinedOutput() // nolint: gosec
+	require.NoError(t, err, string(out))
+
+	return string(out)
+}
+
+// setupGPG creates a GNUPGHOME with the given keys, which have no passphrase.
+func setupGPG(t *testing.T, ids ...string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+
+	home, err := os.MkdirTemp("", "gnupg")
+	require.NoError(t, err)
+
+	t.Setenv("GNUPGHOME", home)
+	t.Cleanup(func() {
+		_ = exec.Command("gpgconf", "--kill", "gpg-agent").Run() // nolint: errcheck
+
+		_ = os.RemoveAll(home) // nolint: errcheck
+	})
+
+	for _, id := range ids {
+		runCommand(t, "gpg", "--batch", "--passphrase", "", "--quick-generate-key", id, "default", "default", "never")
+	}
+}
+
+func setupStore(t *testing.T, ids ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, ".gpg-id"), []byte(strings.Join(ids, "\n")+"\n"), 0o600)
+	require.NoError(t, err)
+
+	return dir
+}
+
+func TestStorage(t *testing.T) {
+	setupGPG(t, "alice@example.org")
+
+	dir := setupStore(t, "alice@example.org")
+	s := pass.New("moneyloverapi.credentials", pass.WithStoreDir(dir))
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// The entry is compatible with pass.
+	path := filepath.Join(dir, "moneyloverapi.credentials", "test.gpg")
+
+	assert.Equal(t, "foobar\n", runCommand(t, "gpg", "--batch", "--quiet", "--decrypt", path))
+
+	// Delete.
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	assert.NoDirExists(t, filepath.Join(dir, "moneyloverapi.credentials"))
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestStorage_PasswordStoreDir(t *testing.T) {
+	setupGPG(t, "alice@example.org")
+
+	dir := setupStore(t, "alice@example.org")
+
+	t.Setenv("PASSWORD_STORE_DIR", dir)
+
+	err := pass.New("moneyloverapi.token").Set("test", "foobar")
+	require.NoError(t, err)
+
+	assert.FileExists(t, filepath.Join(dir, "moneyloverapi.token", "test.gpg"))
+}
+
+func TestStorage_SubfolderGPGID(t *testing.T) {
+	setupGPG(t, "alice@example.org", "bob@example.org")
+
+	dir := setupStore(t, "alice@example.org")
+
+	err := os.MkdirAll(filepath.Join(dir, "moneyloverapi.token"), 0o700)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "moneyloverapi.token", ".gpg-id"), []byte("# comment\nbob@example.org\n"), 0o600)
+	require.NoError(t, err)
+
+	err = pass.New("moneyloverapi.token", pass.WithStoreDir(dir)).Set("test", "foobar")
+	require.NoError(t, err)
+
+	err = pass.New("moneyloverapi.credentials", pass.WithStoreDir(dir)).Set("test", "foobar")
+	require.NoError(t, err)
+
+	out := runCommand(t, "gpg", "--batch", "--list-packets", filepath.Join(dir, "moneyloverapi.token", "test.gpg"))
+	bob := runCommand(t, "gpg", "--batch", "--with-colons", "--list-keys", "bob@example.org")
+	alice := runCommand(t, "gpg", "--batch", "--with-colons", "--list-keys", "alice@example.org")
+
+	assert.Contains(t, out, encryptionKeyID(bob))
+	assert.NotContains(t, out, encryptionKeyID(alice))
+
+	out = runCommand(t, "gpg", "--batch", "--list-packets", filepath.Join(dir, "moneyloverapi.credentials", "test.gpg"))
+
+	assert.Contains(t, out, encryptionKeyID(alice))
+}
+
+func TestStorage_NoGPGID(t *testing.T) {
+	s := pass.New("moneyloverapi.credentials", pass.WithStoreDir(t.TempDir()))
+
+	err := s.Set("test", "foobar")
+
+	assert.ErrorIs(t, err, pass.ErrNoGPGID)
+}
+
+func TestStorage_InvalidKey(t *testing.T) {
+	s := pass.New("moneyloverapi.credentials", pass.WithStoreDir(t.TempDir()))
+
+	_, err := s.Get("../../test")
+
+	assert.ErrorIs(t, err, pass.ErrInvalidKey)
+
+	// The entries of another service.
+	_, err = s.Get("../moneyloverapi.token/test")
+
+	assert.ErrorIs(t, err, pass.ErrInvalidKey)
+
+	err = s.Set("../moneyloverapi.token/test", "foobar")
+
+	assert.ErrorIs(t, err, pass.ErrInvalidKey)
+
+	err = s.Delete("../moneyloverapi.token/test")
+
+	assert.ErrorIs(t, err, pass.ErrInvalidKey)
+}
+
+func TestStorage_Git(t *testing.T) {
+	setupGPG(t, "alice@example.org")
+
+	dir := setupStore(t, "alice@example.org")
+
+	runCommand(t, "git", "-C", dir, "init", "--quiet")
+	runCommand(t, "git", "-C", dir, "config", "user.email", "alice@example.org")
+	runCommand(t, "git", "-C", dir, "config", "user.name", "Alice")
+	runCommand(t, "git", "-C", dir, "config", "commit.gpgsign", "false")
+
+	s := pass.New("moneyloverapi.token", pass.WithStoreDir(dir))
+
+	require.NoError(t, s.Set("test", "foobar"))
+	require.NoError(t, s.Delete("test"))
+
+	expected := "Remove moneyloverapi.token/test from store.\nAdd given password for moneyloverapi.token/test to store.\n"
+
+	assert.Equal(t, expected, runCommand(t, "git", "-C", dir, "log", "--format=%s"))
+}
+
+// encryptionKeyID returns the id of the encryption subkey in the output of gpg --with-colons --list-keys.
+func encryptionKeyID(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, ":")
+
+		if fields[0] == "sub" {
+			return fields[4]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,201 @@
+package moneyloverkeychain
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+const (
+	opGet    = "get"
+	opSet    = "set"
+	opDelete = "delete"
+)
+
+var _ Storage = (*policyStorage)(nil)
+
+// ErrPermissionDenied indicates that a policy denies an operation.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// PermissionError is the error of an operation denied by a policy. It matches ErrPermissionDenied and the reason.
+type PermissionError struct {
+	Op     string
+	Key    string
+	Reason error
+}
+
+// Error satisfies error.
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("%s: %s %q: %s", ErrPermissionDenied.Error(), e.Op, e.Key, e.Reason.Error())
+}
+
+// Is reports whether the target is ErrPermissionDenied.
+func (e *PermissionError) Is(target error) bool {
+	return target == ErrPermissionDenied // nolint: errorlint
+}
+
+// Unwrap returns the reason.
+func (e *PermissionError) Unwrap() error {
+	return e.Reason
+}
+
+var (
+	errKeyNotAllowed = errors.New("key is not allowed")
+	errKeyDenied     = errors.New("key is denied")
+	errDeleteDenied  = errors.New("delete is denied")
+)
+
+// Policy is an access policy for a storage. The keys are matched against the patterns using path.Match, a denied key
+// is never allowed, and an empty allow list allows all the keys.
+type Policy struct {
+	ReadOnly   bool     `json:"read_only" yaml:"read_only"`
+	DenyDelete bool     `json:"deny_delete" yaml:"deny_delete"`
+	Allow      []string `json:"allow" yaml:"allow"`
+	Deny       []string `json:"deny" yaml:"deny"`
+}
+
+// Validate checks the patterns of the policy.
+func (p Policy) Validate() error {
+	for _, patterns := range [][]string{p.Allow, p.Deny} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid key pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// check returns the reason why the operation is denied, or nil if it is allowed.
+func (p Policy) check(op, key string) error {
+	if p.ReadOnly && op != opGet {
+		return ErrReadOnly
+	}
+
+	if p.DenyDelete && op == opDelete {
+		return errDeleteDenied
+	}
+
+	if matched, err := matchKey(p.Deny, key); matched || err != nil {
+		return errKeyDenied
+	}
+
+	if len(p.Allow) == 0 {
+		return nil
+	}
+
+	// An invalid pattern does not allow anything.
+	if matched, err := matchKey(p.Allow, key); !matched || err != nil {
+		return errKeyNotAllowed
+	}
+
+	return nil
+}
+
+func matchKey(patterns []string, key string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			return false, err
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// PolicyStorageOption configures the policy storage.
+type PolicyStorageOption func(p *Policy)
+
+type policyStorage struct {
+	upstream Storage
+	policy   Policy
+}
+
+// Set sets password in the upstream storage for user if the policy allows it.
+func (s *policyStorage) Set(user, password string) error {
+	if err := s.check(opSet, user); err != nil {
+		return err
+	}
+
+	return s.upstream.Set(user, password)
+}
+
+// Get gets password from the upstream storage if the policy allows it.
+func (s *policyStorage) Get(user string) (string, error) {
+	if err := s.check(opGet, user); err != nil {
+		return "", err
+	}
+
+	return s.upstream.Get(user)
+}
+
+// Delete deletes secret from the upstream storage if the policy allows it.
+func (s *policyStorage) Delete(user string) error {
+	if err := s.check(opDelete, user); err != nil {
+		return err
+	}
+
+	return s.upstream.Delete(user)
+}
+
+func (s *policyStorage) check(op, user string) error {
+	if reason := s.policy.check(op, user); reason != nil {
+		return &PermissionError{Op: op, Key: user, Reason: reason}
+	}
+
+	return nil
+}
+
+// NewPolicyStorage creates a storage that enforces an access policy on the upstream storage. The denied operations
+// fail with a *PermissionError.
+func NewPolicyStorage(upstream Storage, options ...PolicyStorageOption) Storage {
+	s := &policyStorage{
+		upstream: upstream,
+	}
+
+	for _, o := range options {
+		o(&s.policy)
+	}
+
+	return s
+}
+
+// WithPolicy sets the policy, such as a policy loaded from a configuration file.
+func WithPolicy(policy Policy) PolicyStorageOption {
+	return func(p *Policy) {
+		*p = policy
+	}
+}
+
+// WithReadOnlyPolicy denies Set and Delete.
+func WithReadOnlyPolicy() PolicyStorageOption {
+	return func(p *Policy) {
+		p.ReadOnly = true
+	}
+}
+
+// WithDenyDeletePolicy denies Delete.
+func WithDenyDeletePolicy() PolicyStorageOption {
+	return func(p *Policy) {
+		p.DenyDelete = true
+	}
+}
+
+// WithAllowedKeys allows only the keys matching the patterns.
+func WithAllowedKeys(patterns ...string) PolicyStorageOption {
+	return func(p *Policy) {
+		p.Allow = append(p.Allow, patterns...)
+	}
+}
+
+// WithDeniedKeys denies the keys matching the patterns.
+func WithDeniedKeys(patterns ...string) PolicyStorageOption {
+	return func(p *Policy) {
+		p.Deny = append(p.Deny, patterns...)
+	}
+}
@@ -0,0 +1,143 @@
+package moneyloverkeychain_test
+
+import (
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+func TestPolicyStorage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario            string
+		options             []moneyloverkeychain.PolicyStorageOption
+		key                 string
+		expectedGetError    string
+		expectedSetError    string
+		expectedDeleteError string
+	}{
+		{
+			scenario: "no policy",
+			key:      "token",
+		},
+		{
+			scenario:            "read-only",
+			options:             []moneyloverkeychain.PolicyStorageOption{moneyloverkeychain.WithReadOnlyPolicy()},
+			key:                 "token",
+			expectedSetError:    `permission denied: set "token": storage is read-only`,
+			expectedDeleteError: `permission denied: delete "token": storage is read-only`,
+		},
+		{
+			scenario:            "deny delete",
+			options:             []moneyloverkeychain.PolicyStorageOption{moneyloverkeychain.WithDenyDeletePolicy()},
+			key:                 "token",
+			expectedDeleteError: `permission denied: delete "token": delete is denied`,
+		},
+		{
+			scenario: "allowed",
+			options:  []moneyloverkeychain.PolicyStorageOption{moneyloverkeychain.WithAllowedKeys("report-*", "token")},
+			key:      "report-daily",
+		},
+		{
+			scenario:            "not allowed",
+			options:             []moneyloverkeychain.PolicyStorageOption{moneyloverkeychain.WithAllowedKeys("report-*")},
+			key:                 "token",
+			expectedGetError:    `permission denied: get "token": key is not allowed`,
+			expectedSetError:    `permission denied: set "token": key is not allowed`,
+			expectedDeleteError: `permission denied: delete "token": key is not allowed`,
+		},
+		{
+			scenario: "denied",
+			options: []moneyloverkeychain.PolicyStorageOption{
+				moneyloverkeychain.WithAllowedKeys("report-*"),
+				moneyloverkeychain.WithDeniedKeys("report-admin"),
+			},
+			key:                 "report-admin",
+			expectedGetError:    `permission denied: get "report-admin": key is denied`,
+			expectedSetError:    `permission denied: set "report-admin": key is denied`,
+			expectedDeleteError: `permission denied: delete "report-admin": key is denied`,
+		},
+		{
+			scenario:            "invalid pattern",
+			options:             []moneyloverkeychain.PolicyStorageOption{moneyloverkeychain.WithAllowedKeys("[")},
+			key:                 "token",
+			expectedGetError:    `permission denied: get "token": key is not allowed`,
+			expectedSetError:    `permission denied: set "token": key is not allowed`,
+			expectedDeleteError: `permission denied: delete "token": key is not allowed`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			upstream := moneyloverkeychain.NewMemoryStorage()
+			upstream.Restore(map[string]string{tc.key: "foobar"})
+
+			s := moneyloverkeychain.NewPolicyStorage(upstream, tc.options...)
+
+			assertPermission := func(t *testing.T, err error, expected string) {
+				t.Helper()
+
+				if expected == "" {
+					require.NoError(t, err)
+
+					return
+				}
+
+				require.EqualError(t, err, expected)
+				assert.ErrorIs(t, err, moneyloverkeychain.ErrPermissionDenied)
+			}
+
+			data, err := s.Get(tc.key)
+
+			if tc.expectedGetError == "" {
+				assert.Equal(t, "foobar", data)
+			}
+
+			assertPermission(t, err, tc.expectedGetError)
+			assertPermission(t, s.Set(tc.key, "foobaz"), tc.expectedSetError)
+			assertPermission(t, s.Delete(tc.key), tc.expectedDeleteError)
+		})
+	}
+}
+
+func TestPolicyStorage_Config(t *testing.T) {
+	t.Parallel()
+
+	var policy moneyloverkeychain.Policy
+
+	err := json.Unmarshal([]byte(`{"read_only":true,"allow":["token"]}`), &policy)
+	require.NoError(t, err)
+	require.NoError(t, policy.Validate())
+
+	upstream := moneyloverkeychain.NewMemoryStorage()
+	upstream.Restore(map[string]string{"token": "foobar"})
+
+	s := moneyloverkeychain.NewPolicyStorage(upstream, moneyloverkeychain.WithPolicy(policy))
+
+	data, err := s.Get("token")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	// The read-only policy is skipped in a chain.
+	fallback := moneyloverkeychain.NewMemoryStorage()
+
+	err = moneyloverkeychain.Chain(s, fallback).Set("token", "foobaz")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"token": "foobar"}, upstream.Snapshot())
+	assert.Equal(t, map[string]string{"token": "foobaz"}, fallback.Snapshot())
+
+	policy.Deny = []string{"["}
+
+	assert.ErrorIs(t, policy.Validate(), path.ErrBadPattern)
+}
@@ -0,0 +1,356 @@
+package moneyloverkeychain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bool64/ctxd"
+)
+
+var _ Storage = (*ReplicatedStorage)(nil)
+
+// ReplicatedStorageOption configures ReplicatedStorage.
+type ReplicatedStorageOption func(s *ReplicatedStorage)
+
+// RepairPolicy decides which replica has the right state of a secret when repairing.
+type RepairPolicy int
+
+const (
+	// RepairPresentWins copies a secret that is present on any replica to the others, preferring the value of the
+	// preferred replica. A delete that did not reach all the replicas is undone, unless it was made by this storage.
+	RepairPresentWins RepairPolicy = iota
+	// RepairPreferredWins makes the other replicas match the preferred replica, so a secret that is missing on the
+	// preferred replica is deleted from the others.
+	RepairPreferredWins
+)
+
+// ReplicaFailure is the error of a replica.
+type ReplicaFailure struct {
+	Replica int
+	Err     error
+}
+
+// ErrNoReplicas indicates that a replicated storage has no replica.
+var ErrNoReplicas = errors.New("replicated storage has no replica")
+
+// ReplicationError reports the replicas that failed an operation.
+type ReplicationError struct {
+	Op        string
+	Succeeded int
+	Quorum    int
+	Failures  []ReplicaFailure
+}
+
+// Error satisfies error.
+func (e *ReplicationError) Error() string {
+	var sb strings.Builder
+
+	sb.WriteString(e.Op)
+
+	if e.Quorum > 0 {
+		_, _ = fmt.Fprintf(&sb, ": %d replicas succeeded, quorum is %d", e.Succeeded, e.Quorum)
+	}
+
+	for _, f := range e.Failures {
+		_, _ = fmt.Fprintf(&sb, "; replica %d: %s", f.Replica, f.Err.Error())
+	}
+
+	return sb.String()
+}
+
+// Is reports whether any of the replicas failed with the target error.
+func (e *ReplicationError) Is(target error) bool {
+	for _, f := range e.Failures {
+		if errors.Is(f.Err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReplicatedStorage is a storage that mirrors the writes to several replicas and reads from the preferred one.
+type ReplicatedStorage struct {
+	replicas  []Storage
+	preferred int
+	quorum    int
+	policy    RepairPolicy
+	logger    ctxd.Logger
+
+	// deletes are the secrets whose delete did not reach all the replicas, they are deleted again by Repair.
+	mu      sync.Mutex
+	deletes map[string]struct{}
+}
+
+// Set sets password in all the replicas. It fails with a *ReplicationError if fewer replicas than the write quorum
+// succeed, the other failures are logged.
+func (s *ReplicatedStorage) Set(user, password string) error {
+	if len(s.replicas) == 0 {
+		return ErrNoReplicas
+	}
+
+	s.setPendingDelete(user, false)
+
+	return s.write("set", func(r Storage) error {
+		return r.Set(user, password)
+	})
+}
+
+// Get gets password from the preferred replica, or from the other replicas in order if the preferred one fails with
+// an error other than ErrNotFound.
+func (s *ReplicatedStorage) Get(user string) (string, error) {
+	if len(s.replicas) == 0 {
+		return "", ErrNoReplicas
+	}
+
+	password, err := s.replicas[s.preferred].Get(user)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return password, err
+	}
+
+	s.logger.Warn(context.Background(), "could not get secret from preferred replica",
+		"replica", s.preferred, "error", err,
+	)
+
+	for i, r := range s.replicas {
+		if i == s.preferred {
+			continue
+		}
+
+		if password, err := r.Get(user); err == nil || errors.Is(err, ErrNotFound) {
+			return password, err
+		}
+	}
+
+	return "", err
+}
+
+// Delete deletes secret from all the replicas. A replica that does not have the secret counts as succeeded, ErrNotFound
+// is returned only if none of the replicas has it.
+func (s *ReplicatedStorage) Delete(user string) error {
+	if len(s.replicas) == 0 {
+		return ErrNoReplicas
+	}
+
+	found := false
+	partial := false
+
+	err := s.write("delete", func(r Storage) error {
+		err := r.Delete(user)
+		if err == nil {
+			found = true
+		} else if errors.Is(err, ErrNotFound) {
+			return nil
+		} else {
+			partial = true
+		}
+
+		return err
+	})
+
+	// The replicas that still have the secret are deleted by Repair instead of being copied to the others.
+	s.setPendingDelete(user, partial)
+
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Repair reconciles the secrets of the users across the replicas according to the repair policy, see
+// WithRepairPolicy. A secret whose delete by this storage did not reach all the replicas is deleted from all of them.
+func (s *ReplicatedStorage) Repair(users ...string) error {
+	if len(s.replicas) == 0 {
+		return ErrNoReplicas
+	}
+
+	e := &ReplicationError{Op: "repair"}
+
+	for _, user := range users {
+		failures := len(e.Failures)
+		values := make([]*string, len(s.replicas))
+		failed := make([]bool, len(s.replicas))
+
+		for _, i := range s.readOrder() {
+			password, err := s.replicas[i].Get(user)
+
+			switch {
+			case err == nil:
+				values[i] = &password
+
+			case !errors.Is(err, ErrNotFound):
+				failed[i] = true
+
+				e.Failures = append(e.Failures, ReplicaFailure{Replica: i, Err: err})
+			}
+		}
+
+		source, ok := s.repairSource(user, values, failed)
+		if !ok {
+			continue
+		}
+
+		for i, r := range s.replicas {
+			if failed[i] {
+				continue
+			}
+
+			var err error
+
+			switch {
+			case source == nil && values[i] != nil:
+				if err = r.Delete(user); errors.Is(err, ErrNotFound) {
+					err = nil
+				}
+
+			case source != nil && (values[i] == nil || *values[i] != *source):
+				err = r.Set(user, *source)
+			}
+
+			if err != nil {
+				e.Failures = append(e.Failures, ReplicaFailure{Replica: i, Err: err})
+			}
+		}
+
+		if source == nil && len(e.Failures) == failures {
+			s.setPendingDelete(user, false)
+		}
+	}
+
+	if len(e.Failures) > 0 {
+		return e
+	}
+
+	return nil
+}
+
+// repairSource returns the value that the replicas should have, or nil if the secret should be deleted. It returns
+// false if the replicas can not be repaired.
+func (s *ReplicatedStorage) repairSource(user string, values []*string, failed []bool) (*string, bool) {
+	s.mu.Lock()
+	_, deleted := s.deletes[user]
+	s.mu.Unlock()
+
+	if deleted {
+		return nil, true
+	}
+
+	if s.policy == RepairPreferredWins {
+		return values[s.preferred], !failed[s.preferred]
+	}
+
+	for _, i := range s.readOrder() {
+		if values[i] != nil {
+			return values[i], true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *ReplicatedStorage) setPendingDelete(user string, pending bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending {
+		s.deletes[user] = struct{}{}
+	} else {
+		delete(s.deletes, user)
+	}
+}
+
+func (s *ReplicatedStorage) write(op string, fn func(r Storage) error) error {
+	e := &ReplicationError{Op: op, Quorum: s.quorum}
+
+	for i, r := range s.replicas {
+		if err := fn(r); err != nil {
+			e.Failures = append(e.Failures, ReplicaFailure{Replica: i, Err: err})
+
+			continue
+		}
+
+		e.Succeeded++
+	}
+
+	if e.Succeeded < e.Quorum {
+		return e
+	}
+
+	if len(e.Failures) > 0 {
+		s.logger.Warn(context.Background(), "could not replicate secret", "error", e.Error())
+	}
+
+	return nil
+}
+
+// readOrder returns the indexes of the replicas with the preferred one first.
+func (s *ReplicatedStorage) readOrder() []int {
+	order := make([]int, 0, len(s.replicas))
+	order = append(order, s.preferred)
+
+	for i := range s.replicas {
+		if i != s.preferred {
+			order = append(order, i)
+		}
+	}
+
+	return order
+}
+
+// NewReplicatedStorage creates a storage that mirrors the writes to all the replicas. By default, the first replica is
+// preferred for reading and all the replicas must succeed a write. The operations fail with ErrNoReplicas if there is
+// no replica.
+func NewReplicatedStorage(replicas []Storage, options ...ReplicatedStorageOption) *ReplicatedStorage {
+	s := &ReplicatedStorage{
+		replicas: replicas,
+		quorum:   len(replicas),
+		logger:   ctxd.NoOpLogger{},
+		deletes:  make(map[string]struct{}),
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithWriteQuorum sets the number of replicas that must succeed a write.
+func WithWriteQuorum(quorum int) ReplicatedStorageOption {
+	return func(s *ReplicatedStorage) {
+		if quorum > 0 && quorum <= len(s.replicas) {
+			s.quorum = quorum
+		}
+	}
+}
+
+// WithPreferredReplica sets the index of the replica to read from.
+func WithPreferredReplica(i int) ReplicatedStorageOption {
+	return func(s *ReplicatedStorage) {
+		if i >= 0 && i < len(s.replicas) {
+			s.preferred = i
+		}
+	}
+}
+
+// WithRepairPolicy sets the policy of Repair, which is RepairPresentWins by default.
+func WithRepairPolicy(policy RepairPolicy) ReplicatedStorageOption {
+	return func(s *ReplicatedStorage) {
+		s.policy = policy
+	}
+}
+
+// WithReplicationLogger sets the logger for the failures that do not break the write quorum.
+func WithReplicationLogger(logger ctxd.Logger) ReplicatedStorageOption {
+	return func(s *ReplicatedStorage) {
+		s.logger = logger
+	}
+}
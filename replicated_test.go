@@ -0,0 +1,259 @@
+package moneyloverkeychain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+func TestReplicatedStorage(t *testing.T) {
+	t.Parallel()
+
+	primary := moneyloverkeychain.NewMemoryStorage()
+	backup := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewReplicatedStorage([]moneyloverkeychain.Storage{primary, backup})
+
+	// Get not found.
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("test", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"test": "foobar"}, primary.Snapshot())
+	assert.Equal(t, map[string]string{"test": "foobar"}, backup.Snapshot())
+
+	// Delete.
+	require.NoError(t, primary.Delete("test"))
+
+	err = s.Delete("test")
+	require.NoError(t, err)
+
+	assert.Empty(t, backup.Snapshot())
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestReplicatedStorage_Quorum(t *testing.T) {
+	t.Parallel()
+
+	// All replicas.
+	broken := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Set", "test", "foobar").Return(errors.New("set error"))
+	})(t)
+
+	backup := moneyloverkeychain.NewMemoryStorage()
+	s := moneyloverkeychain.NewReplicatedStorage([]moneyloverkeychain.Storage{broken, backup})
+
+	err := s.Set("test", "foobar")
+
+	require.EqualError(t, err, "set: 1 replicas succeeded, quorum is 2; replica 0: set error")
+
+	var replicationErr *moneyloverkeychain.ReplicationError
+
+	require.True(t, errors.As(err, &replicationErr))
+
+	expected := &moneyloverkeychain.ReplicationError{
+		Op:        "set",
+		Succeeded: 1,
+		Quorum:    2,
+		Failures:  []moneyloverkeychain.ReplicaFailure{{Replica: 0, Err: errors.New("set error")}},
+	}
+
+	assert.Equal(t, expected, replicationErr)
+
+	// Quorum of one.
+	broken = mock.MockStorage(func(s *mock.Storage) {
+		s.On("Set", "test", "foobar").Return(errors.New("set error"))
+		s.On("Delete", "test").Return(errors.New("delete error"))
+	})(t)
+
+	l := &ctxd.LoggerMock{}
+	s = moneyloverkeychain.NewReplicatedStorage([]moneyloverkeychain.Storage{broken, backup},
+		moneyloverkeychain.WithWriteQuorum(1),
+		moneyloverkeychain.WithReplicationLogger(l),
+	)
+
+	require.NoError(t, s.Set("test", "foobar"))
+	require.NoError(t, s.Delete("test"))
+
+	assert.Empty(t, backup.Snapshot())
+
+	expectedLogs := `warn: could not replicate secret {"error":"set: 1 replicas succeeded, quorum is 1; replica 0: set error"}
+warn: could not replicate secret {"error":"delete: 1 replicas succeeded, quorum is 1; replica 0: delete error"}
+`
+
+	assert.Equal(t, expectedLogs, l.String())
+}
+
+func TestReplicatedStorage_Get(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario       string
+		mockPreferred  mock.StorageMocker
+		expectedResult string
+		expectedError  error
+	}{
+		{
+			scenario: "preferred",
+			mockPreferred: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Get", "test").Return("preferred", nil)
+			}),
+			expectedResult: "preferred",
+		},
+		{
+			scenario: "preferred not found",
+			mockPreferred: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Get", "test").Return("", moneyloverkeychain.ErrNotFound)
+			}),
+			expectedError: moneyloverkeychain.ErrNotFound,
+		},
+		{
+			scenario: "preferred fails",
+			mockPreferred: mock.MockStorage(func(s *mock.Storage) {
+				s.On("Get", "test").Return("", errors.New("get error"))
+			}),
+			expectedResult: "backup",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			backup := moneyloverkeychain.NewMemoryStorage()
+			backup.Restore(map[string]string{"test": "backup"})
+
+			s := moneyloverkeychain.NewReplicatedStorage([]moneyloverkeychain.Storage{backup, tc.mockPreferred(t)},
+				moneyloverkeychain.WithPreferredReplica(1),
+			)
+
+			data, err := s.Get("test")
+
+			assert.Equal(t, tc.expectedResult, data)
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestReplicatedStorage_Repair(t *testing.T) {
+	t.Parallel()
+
+	r0 := moneyloverkeychain.NewMemoryStorage()
+	r1 := moneyloverkeychain.NewMemoryStorage()
+	r2 := moneyloverkeychain.NewMemoryStorage()
+
+	r0.Restore(map[string]string{"diverged": "old", "missing": "foobar"})
+	r1.Restore(map[string]string{"diverged": "new"})
+	r2.Restore(map[string]string{"diverged": "other", "in sync": "foobar"})
+
+	s := moneyloverkeychain.NewReplicatedStorage([]moneyloverkeychain.Storage{r0, r1, r2},
+		moneyloverkeychain.WithPreferredReplica(1),
+	)
+
+	err := s.Repair("diverged", "missing", "in sync", "unknown")
+	require.NoError(t, err)
+
+	expected := map[string]string{"diverged": "new", "missing": "foobar", "in sync": "foobar"}
+
+	assert.Equal(t, expected, r0.Snapshot())
+	assert.Equal(t, expected, r1.Snapshot())
+	assert.Equal(t, expected, r2.Snapshot())
+
+	// Failures.
+	broken := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Get", "test").Return("", errors.New("get error"))
+	})(t)
+
+	s = moneyloverkeychain.NewReplicatedStorage([]moneyloverkeychain.Storage{broken, r0})
+
+	require.NoError(t, r0.Set("test", "foobar"))
+
+	err = s.Repair("test")
+
+	require.EqualError(t, err, "repair; replica 0: get error")
+}
+
+func TestReplicatedStorage_RepairPartialDelete(t *testing.T) {
+	t.Parallel()
+
+	r0 := moneyloverkeychain.NewMemoryStorage()
+	r1 := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Delete", "test").Return(errors.New("delete error")).Once()
+		s.On("Get", "test").Return("foobar", nil).Once()
+		s.On("Delete", "test").Return(nil).Once()
+	})(t)
+
+	require.NoError(t, r0.Set("test", "foobar"))
+
+	s := moneyloverkeychain.NewReplicatedStorage([]moneyloverkeychain.Storage{r0, r1},
+		moneyloverkeychain.WithWriteQuorum(1),
+	)
+
+	require.NoError(t, s.Delete("test"))
+
+	// The secret is deleted from the replica that missed the delete instead of being copied back.
+	require.NoError(t, s.Repair("test"))
+
+	assert.Empty(t, r0.Snapshot())
+}
+
+func TestReplicatedStorage_RepairPreferredWins(t *testing.T) {
+	t.Parallel()
+
+	r0 := moneyloverkeychain.NewMemoryStorage()
+	r1 := moneyloverkeychain.NewMemoryStorage()
+
+	r0.Restore(map[string]string{"diverged": "old"})
+	r1.Restore(map[string]string{"diverged": "new", "deleted": "foobar"})
+
+	s := moneyloverkeychain.NewReplicatedStorage([]moneyloverkeychain.Storage{r0, r1},
+		moneyloverkeychain.WithRepairPolicy(moneyloverkeychain.RepairPreferredWins),
+	)
+
+	err := s.Repair("diverged", "deleted")
+	require.NoError(t, err)
+
+	expected := map[string]string{"diverged": "old"}
+
+	assert.Equal(t, expected, r0.Snapshot())
+	assert.Equal(t, expected, r1.Snapshot())
+}
+
+func TestReplicatedStorage_NoReplicas(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewReplicatedStorage(nil)
+
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNoReplicas)
+
+	assert.ErrorIs(t, s.Set("test", "foobar"), moneyloverkeychain.ErrNoReplicas)
+	assert.ErrorIs(t, s.Delete("test"), moneyloverkeychain.ErrNoReplicas)
+	assert.ErrorIs(t, s.Repair("test"), moneyloverkeychain.ErrNoReplicas)
+}
@@ -0,0 +1,244 @@
+package moneyloverkeychain
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	// DefaultRetryAttempts is the default number of attempts of an operation.
+	DefaultRetryAttempts = 5
+	// DefaultRetryInitialBackoff is the default backoff before the first retry.
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	// DefaultRetryMaxBackoff is the default maximum backoff between two attempts.
+	DefaultRetryMaxBackoff = 2 * time.Second
+)
+
+var _ Storage = (*retryStorage)(nil)
+
+// transientDBusErrors are the D-Bus errors of a service that is not started yet or not responding.
+var transientDBusErrors = []string{
+	"org.freedesktop.DBus.Error.ServiceUnknown",
+	"org.freedesktop.DBus.Error.NameHasNoOwner",
+	"org.freedesktop.DBus.Error.NoReply",
+	"org.freedesktop.DBus.Error.NoServer",
+	"org.freedesktop.DBus.Error.Timeout",
+	"org.freedesktop.DBus.Error.TimedOut",
+	"org.freedesktop.DBus.Error.Disconnected",
+	"org.freedesktop.DBus.Error.Spawn.",
+	"org.freedesktop.Secret.Error.IsLocked",
+}
+
+// transientMessages are the messages of the transient errors whose type is lost.
+var transientMessages = []string{
+	"was not provided by any .service files",
+	"not activatable",
+	"failed to unlock correct collection",
+	"collection is locked",
+}
+
+// RetryStorageOption configures the retry storage.
+type RetryStorageOption func(s *retryStorage)
+
+type retryStorage struct {
+	upstream Storage
+	logger   ctxd.Logger
+
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	isTransient    func(err error) bool
+}
+
+// Set sets password in the upstream storage for user, and retries if it fails with a transient error.
+func (s *retryStorage) Set(user, password string) error {
+	return s.do("set", user, func() error {
+		return s.upstream.Set(user, password)
+	})
+}
+
+// Get gets password from the upstream storage, and retries if it fails with a transient error.
+func (s *retryStorage) Get(user string) (string, error) {
+	var password string
+
+	err := s.do("get", user, func() error {
+		var err error
+
+		password, err = s.upstream.Get(user)
+
+		return err
+	})
+
+	return password, err
+}
+
+// Delete deletes secret from the upstream storage, and retries if it fails with a transient error.
+func (s *retryStorage) Delete(user string) error {
+	return s.do("delete", user, func() error {
+		return s.upstream.Delete(user)
+	})
+}
+
+func (s *retryStorage) do(op, user string, fn func() error) error {
+	ctx := context.Background()
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if !s.isTransient(err) {
+			switch {
+			case attempt > 1:
+				s.logger.Warn(ctx, "storage operation failed with a permanent error",
+					"operation", op, "key", user, "attempt", attempt, "error", err,
+				)
+
+			case !errors.Is(err, ErrNotFound):
+				// A missing secret is too common to be logged.
+				s.logger.Debug(ctx, "storage operation failed with a permanent error",
+					"operation", op, "key", user, "attempt", attempt, "error", err,
+				)
+			}
+
+			return err
+		}
+
+		if attempt >= s.attempts {
+			s.logger.Error(ctx, "storage operation failed after retries",
+				"operation", op, "key", user, "attempt", attempt, "error", err,
+			)
+
+			return err
+		}
+
+		backoff := s.backoff(attempt)
+
+		s.logger.Debug(ctx, "retrying storage operation after a transient error",
+			"operation", op, "key", user, "attempt", attempt, "backoff", backoff.String(), "error", err,
+		)
+
+		time.Sleep(backoff)
+	}
+}
+
+// backoff returns a random duration up to the exponential backoff of the attempt, capped at the max backoff.
+func (s *retryStorage) backoff(attempt int) time.Duration {
+	backoff := s.initialBackoff
+
+	for i := 1; i < attempt && backoff < s.maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > s.maxBackoff {
+		backoff = s.maxBackoff
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(backoff)) + 1) // nolint: gosec
+}
+
+// IsTransientError reports whether an operation that failed with the error could succeed on retry, such as when the
+// Secret Service is not started yet, the collection is locked or the operation timed out. ErrNotFound,
+// keyring.ErrSetDataTooBig, ErrReadOnly and ErrPermissionDenied are never transient.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	for _, permanent := range []error{ErrNotFound, keyring.ErrSetDataTooBig, ErrReadOnly, ErrPermissionDenied} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	if name, ok := dbusErrorName(err); ok {
+		for _, transient := range transientDBusErrors {
+			if strings.HasPrefix(name, transient) {
+				return true
+			}
+		}
+	}
+
+	msg := err.Error()
+
+	for _, transient := range transientMessages {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewRetryStorage creates a storage that retries the operations failing with a transient error, with a jittered
+// exponential backoff. By default, the errors are classified by IsTransientError.
+func NewRetryStorage(upstream Storage, options ...RetryStorageOption) Storage {
+	s := &retryStorage{
+		upstream:       upstream,
+		logger:         ctxd.NoOpLogger{},
+		attempts:       DefaultRetryAttempts,
+		initialBackoff: DefaultRetryInitialBackoff,
+		maxBackoff:     DefaultRetryMaxBackoff,
+		isTransient:    IsTransientError,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithRetryAttempts sets the maximum number of attempts of an operation, including the first one.
+func WithRetryAttempts(attempts int) RetryStorageOption {
+	return func(s *retryStorage) {
+		if attempts > 0 {
+			s.attempts = attempts
+		}
+	}
+}
+
+// WithRetryBackoff sets the backoff before the first retry and the maximum backoff.
+func WithRetryBackoff(initial, maxBackoff time.Duration) RetryStorageOption {
+	return func(s *retryStorage) {
+		s.initialBackoff = initial
+		s.maxBackoff = maxBackoff
+	}
+}
+
+// WithRetryClassifier sets the function that decides whether an error is transient.
+func WithRetryClassifier(isTransient func(err error) bool) RetryStorageOption {
+	return func(s *retryStorage) {
+		s.isTransient = isTransient
+	}
+}
+
+// WithRetryLogger sets the logger for the retries and the final classification of the errors.
+func WithRetryLogger(logger ctxd.Logger) RetryStorageOption {
+	return func(s *retryStorage) {
+		s.logger = logger
+	}
+}
@@ -0,0 +1,27 @@
+//go:build dragonfly || freebsd || linux || netbsd || openbsd
+// +build dragonfly freebsd linux netbsd openbsd
+
+package moneyloverkeychain
+
+import (
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// dbusErrorName returns the name of the D-Bus error of the secret service.
+func dbusErrorName(err error) (string, bool) {
+	var dbusErr dbus.Error
+
+	if errors.As(err, &dbusErr) {
+		return dbusErr.Name, true
+	}
+
+	var dbusErrPtr *dbus.Error
+
+	if errors.As(err, &dbusErrPtr) {
+		return dbusErrPtr.Name, true
+	}
+
+	return "", false
+}
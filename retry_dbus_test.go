@@ -0,0 +1,96 @@
+//go:build dragonfly || freebsd || linux || netbsd || openbsd
+// +build dragonfly freebsd linux netbsd openbsd
+
+package moneyloverkeychain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+func TestRetryStorage(t *testing.T) {
+	t.Parallel()
+
+	transient := dbus.Error{
+		Name: "org.freedesktop.DBus.Error.ServiceUnknown",
+		Body: []interface{}{"The name org.freedesktop.secrets was not provided by any .service files"},
+	}
+
+	upstream := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Set", "test", "foobar").Return(transient).Twice()
+		s.On("Set", "test", "foobar").Return(nil).Once()
+
+		s.On("Get", "test").Return("", transient).Once()
+		s.On("Get", "test").Return("foobar", nil).Once()
+
+		s.On("Delete", "test").Return(transient).Once()
+		s.On("Delete", "test").Return(moneyloverkeychain.ErrNotFound).Once()
+	})(t)
+
+	l := &ctxd.LoggerMock{}
+	s := moneyloverkeychain.NewRetryStorage(upstream,
+		moneyloverkeychain.WithRetryBackoff(time.Millisecond, 5*time.Millisecond),
+		moneyloverkeychain.WithRetryLogger(l),
+	)
+
+	require.NoError(t, s.Set("test", "foobar"))
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	err = s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	assert.Contains(t, l.String(), `debug: retrying storage operation after a transient error {"attempt":2,`)
+	assert.Contains(t, l.String(), `warn: storage operation failed with a permanent error {"attempt":2,`)
+}
+
+func TestIsTransientError_DBus(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		err      error
+		expected bool
+	}{
+		{
+			scenario: "service unknown",
+			err:      dbus.Error{Name: "org.freedesktop.DBus.Error.ServiceUnknown"},
+			expected: true,
+		},
+		{
+			scenario: "service not activatable",
+			err:      dbus.NewError("org.freedesktop.DBus.Error.Spawn.ServiceNotFound", nil),
+			expected: true,
+		},
+		{
+			scenario: "locked collection",
+			err:      dbus.Error{Name: "org.freedesktop.Secret.Error.IsLocked"},
+			expected: true,
+		},
+		{
+			scenario: "unknown dbus error",
+			err:      dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, moneyloverkeychain.IsTransientError(tc.err))
+		})
+	}
+}
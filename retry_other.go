@@ -0,0 +1,9 @@
+//go:build !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package moneyloverkeychain
+
+// dbusErrorName returns false because the keyring does not use D-Bus on the platform.
+func dbusErrorName(error) (string, bool) {
+	return "", false
+}
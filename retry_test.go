@@ -0,0 +1,135 @@
+package moneyloverkeychain_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bool64/ctxd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/mock"
+)
+
+func TestRetryStorage_Attempts(t *testing.T) {
+	t.Parallel()
+
+	upstream := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Get", "test").Return("", context.DeadlineExceeded).Times(3)
+	})(t)
+
+	l := &ctxd.LoggerMock{}
+	s := moneyloverkeychain.NewRetryStorage(upstream,
+		moneyloverkeychain.WithRetryAttempts(3),
+		moneyloverkeychain.WithRetryBackoff(time.Millisecond, time.Millisecond),
+		moneyloverkeychain.WithRetryLogger(l),
+	)
+
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.Contains(t, l.String(), `error: storage operation failed after retries {"attempt":3,`)
+}
+
+func TestRetryStorage_Classifier(t *testing.T) {
+	t.Parallel()
+
+	upstream := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Set", "test", "foobar").Return(errors.New("busy")).Once()
+		s.On("Set", "test", "foobar").Return(nil).Once()
+	})(t)
+
+	s := moneyloverkeychain.NewRetryStorage(upstream,
+		moneyloverkeychain.WithRetryBackoff(0, 0),
+		moneyloverkeychain.WithRetryClassifier(func(err error) bool {
+			return err.Error() == "busy"
+		}),
+	)
+
+	require.NoError(t, s.Set("test", "foobar"))
+}
+
+func TestRetryStorage_PermanentError(t *testing.T) {
+	t.Parallel()
+
+	upstream := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Set", "test", "foobar").Return(keyring.ErrSetDataTooBig).Once()
+		s.On("Get", "test").Return("", moneyloverkeychain.ErrNotFound).Once()
+	})(t)
+
+	l := &ctxd.LoggerMock{}
+	s := moneyloverkeychain.NewRetryStorage(upstream,
+		moneyloverkeychain.WithRetryLogger(l),
+	)
+
+	err := s.Set("test", "foobar")
+
+	assert.ErrorIs(t, err, keyring.ErrSetDataTooBig)
+	assert.Contains(t, l.String(), `debug: storage operation failed with a permanent error {"attempt":1,`)
+
+	// A missing secret is not logged.
+	_, err = s.Get("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+	assert.NotContains(t, l.String(), `"operation":"get"`)
+}
+
+func TestIsTransientError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		err      error
+		expected bool
+	}{
+		{
+			scenario: "nil",
+		},
+		{
+			scenario: "not found",
+			err:      moneyloverkeychain.ErrNotFound,
+		},
+		{
+			scenario: "too big",
+			err:      keyring.ErrSetDataTooBig,
+		},
+		{
+			scenario: "read-only",
+			err:      moneyloverkeychain.ErrReadOnly,
+		},
+		{
+			scenario: "permission denied",
+			err:      fmt.Errorf("%w: get", moneyloverkeychain.ErrPermissionDenied),
+		},
+		{
+			scenario: "unknown",
+			err:      errors.New("unknown"),
+		},
+		{
+			scenario: "deadline exceeded",
+			err:      fmt.Errorf("get: %w", context.DeadlineExceeded),
+			expected: true,
+		},
+		{
+			scenario: "unlock failed",
+			err:      errors.New("failed to unlock correct collection '/org/freedesktop/secrets/aliases/default'"),
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, moneyloverkeychain.IsTransientError(tc.err))
+		})
+	}
+}
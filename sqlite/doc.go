@@ -0,0 +1,2 @@
+// Package sqlite provides a storage in a SQLite database using a cgo-free driver.
+package sqlite
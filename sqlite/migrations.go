@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsupportedSchema indicates that the database was migrated by a newer version of the storage.
+var ErrUnsupportedSchema = errors.New("unsupported schema")
+
+// migrations are applied in order, the version of a migration is its index plus one. A migration must never be changed
+// once released, new fields are added by appending a new migration.
+var migrations = []string{
+	`CREATE TABLE secrets (
+		service    TEXT    NOT NULL,
+		key        TEXT    NOT NULL,
+		value      BLOB    NOT NULL,
+		encrypted  INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT    NOT NULL,
+		updated_at TEXT    NOT NULL,
+		PRIMARY KEY (service, key)
+	)`,
+}
+
+// SchemaVersion is the version of the schema after all the migrations are applied.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// Migrate applies the pending migrations to the database.
+func Migrate(db *sql.DB) (err error) {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER NOT NULL PRIMARY KEY,
+		applied_at TEXT    NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("could not create migrations table: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback() // nolint: errcheck
+		}
+	}()
+
+	var current int
+
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	if current > len(migrations) {
+		return fmt.Errorf("%w: database version %d is newer than %d", ErrUnsupportedSchema, current, len(migrations))
+	}
+
+	for i := current; i < len(migrations); i++ {
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			return fmt.Errorf("could not apply migration %d: %w", i+1, err)
+		}
+
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			i+1, formatTime(time.Now()),
+		); err != nil {
+			return fmt.Errorf("could not record migration %d: %w", i+1, err)
+		}
+	}
+
+	return tx.Commit()
+}
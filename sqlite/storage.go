@@ -0,0 +1,201 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	// Register the cgo-free sqlite driver.
+	_ "modernc.org/sqlite"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/internal/seal"
+)
+
+const (
+	// timeLayout is compatible with the SQLite date and time functions.
+	timeLayout = "2006-01-02 15:04:05.000"
+
+	// busyTimeout is how long a write waits for the other connections to release the database.
+	busyTimeout = 5 * time.Second
+)
+
+var _ moneyloverkeychain.Storage = (*Storage)(nil)
+
+// ErrNoEncryptionKey indicates that a value is encrypted but the storage has no encryption key.
+var ErrNoEncryptionKey = errors.New("value is encrypted but no encryption key is configured")
+
+// Option configures Storage.
+type Option func(s *Storage)
+
+// Storage is a storage in the secrets table of a SQLite database.
+type Storage struct {
+	db      *sql.DB
+	ownDB   bool
+	service string
+
+	keySource moneyloverkeychain.KeySource
+}
+
+// Set sets password in the database for user.
+func (s *Storage) Set(user, password string) error {
+	value := []byte(password)
+	encrypted := false
+
+	if s.keySource != nil {
+		key, err := s.keySource()
+		if err != nil {
+			return err
+		}
+
+		if value, err = seal.Seal(key, value, seal.AdditionalData(s.service, user)); err != nil {
+			return err
+		}
+
+		encrypted = true
+	}
+
+	now := formatTime(time.Now())
+
+	_, err := s.db.Exec(`INSERT INTO secrets (service, key, value, encrypted, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (service, key) DO UPDATE SET
+			value = excluded.value,
+			encrypted = excluded.encrypted,
+			updated_at = excluded.updated_at`,
+		s.service, user, value, encrypted, now, now,
+	)
+
+	return err
+}
+
+// Get gets password from the database.
+func (s *Storage) Get(user string) (string, error) {
+	var (
+		value     []byte
+		encrypted bool
+	)
+
+	err := s.db.QueryRow(`SELECT value, encrypted FROM secrets WHERE service = ? AND key = ?`, s.service, user).
+		Scan(&value, &encrypted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", moneyloverkeychain.ErrNotFound
+		}
+
+		return "", err
+	}
+
+	if !encrypted {
+		return string(value), nil
+	}
+
+	if s.keySource == nil {
+		return "", ErrNoEncryptionKey
+	}
+
+	key, err := s.keySource()
+	if err != nil {
+		return "", err
+	}
+
+	password, err := seal.Open(key, value, seal.AdditionalData(s.service, user))
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt value: %w", err)
+	}
+
+	return string(password), nil
+}
+
+// Delete deletes secret from the database.
+func (s *Storage) Delete(user string) error {
+	res, err := s.db.Exec(`DELETE FROM secrets WHERE service = ? AND key = ?`, s.service, user)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return moneyloverkeychain.ErrNotFound
+	}
+
+	return nil
+}
+
+// Close closes the database if it was opened by Open.
+func (s *Storage) Close() error {
+	if !s.ownDB {
+		return nil
+	}
+
+	return s.db.Close()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// New creates a storage in an opened database and applies the pending migrations. The database is not closed by Close.
+func New(db *sql.DB, service string, options ...Option) (*Storage, error) {
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+
+	s := &Storage{
+		db:      db,
+		service: service,
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s, nil
+}
+
+// Open opens or creates the database file and creates a storage in it. A new file is only accessible by the owner, and
+// a locked database is retried for busyTimeout so that several processes can share it.
+func Open(path, service string, options ...Option) (*Storage, error) {
+	// SQLite would create the file with the default permissions.
+	f, err := os.OpenFile(filepath.Clean(path), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", fmt.Sprintf("%s?_pragma=busy_timeout(%d)", path, busyTimeout.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows only one writer at a time.
+	db.SetMaxOpenConns(1)
+
+	s, err := New(db, service, options...)
+	if err != nil {
+		_ = db.Close() // nolint: errcheck
+
+		return nil, err
+	}
+
+	s.ownDB = true
+
+	return s, nil
+}
+
+// WithEncryption encrypts the values with AES-256-GCM. The values written without encryption are still readable.
+func WithEncryption(key moneyloverkeychain.KeySource) Option {
+	return func(s *Storage) {
+		s.keySource = moneyloverkeychain.CachedKey(key)
+	}
+}
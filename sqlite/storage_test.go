@@ -0,0 +1,302 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/sqlite"
+)
+
+var testKey = moneyloverkeychain.StaticKey([]byte("0123456789abcdef0123456789abcdef"))
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "secrets.db"))
+	require.NoError(t, err)
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() {
+		_ = db.Close() // nolint: errcheck
+	})
+
+	return db
+}
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		options  []sqlite.Option
+	}{
+		{
+			scenario: "plaintext",
+		},
+		{
+			scenario: "encrypted",
+			options:  []sqlite.Option{sqlite.WithEncryption(testKey)},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "secrets.db")
+
+			s, err := sqlite.Open(path, "service", tc.options...)
+			require.NoError(t, err)
+
+			// Get not found.
+			data, err := s.Get("test")
+
+			assert.Empty(t, data)
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+			// Set.
+			err = s.Set("test", "foobar")
+			require.NoError(t, err)
+
+			data, err = s.Get("test")
+
+			assert.Equal(t, "foobar", data)
+			require.NoError(t, err)
+
+			err = s.Set("test", "foobaz")
+			require.NoError(t, err)
+
+			// Reopen.
+			require.NoError(t, s.Close())
+
+			s, err = sqlite.Open(path, "service", tc.options...)
+			require.NoError(t, err)
+
+			defer s.Close() // nolint: errcheck
+
+			data, err = s.Get("test")
+
+			assert.Equal(t, "foobaz", data)
+			require.NoError(t, err)
+
+			// Delete.
+			err = s.Delete("test")
+			require.NoError(t, err)
+
+			data, err = s.Get("test")
+
+			assert.Empty(t, data)
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+			err = s.Delete("test")
+
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+		})
+	}
+}
+
+func TestStorage_Schema(t *testing.T) {
+	t.Parallel()
+
+	db := openTestDB(t)
+
+	credentials, err := sqlite.New(db, "credentials", sqlite.WithEncryption(testKey))
+	require.NoError(t, err)
+
+	token, err := sqlite.New(db, "token")
+	require.NoError(t, err)
+
+	require.NoError(t, credentials.Set("john", "secret"))
+	require.NoError(t, token.Set("john", "token"))
+	require.NoError(t, token.Set("john", "new-token"))
+
+	rows, err := db.Query(`SELECT service, key, value, encrypted, created_at <= updated_at FROM secrets ORDER BY service`)
+	require.NoError(t, err)
+
+	defer rows.Close() // nolint: errcheck
+
+	type row struct {
+		service, key string
+		value        []byte
+		encrypted    bool
+		ordered      bool
+	}
+
+	var actual []row
+
+	for rows.Next() {
+		var r row
+
+		require.NoError(t, rows.Scan(&r.service, &r.key, &r.value, &r.encrypted, &r.ordered))
+
+		actual = append(actual, r)
+	}
+
+	require.NoError(t, rows.Err())
+	require.Len(t, actual, 2)
+
+	assert.Equal(t, "credentials", actual[0].service)
+	assert.Equal(t, "john", actual[0].key)
+	assert.NotContains(t, string(actual[0].value), "secret")
+	assert.True(t, actual[0].encrypted)
+	assert.True(t, actual[0].ordered)
+
+	assert.Equal(t, "token", actual[1].service)
+	assert.Equal(t, "john", actual[1].key)
+	assert.Equal(t, "new-token", string(actual[1].value))
+	assert.False(t, actual[1].encrypted)
+	assert.True(t, actual[1].ordered)
+
+	// The encrypted value can not be read without the key.
+	plaintext, err := sqlite.New(db, "credentials")
+	require.NoError(t, err)
+
+	data, err := plaintext.Get("john")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, sqlite.ErrNoEncryptionKey)
+
+	// The plaintext value is still readable with the key.
+	encrypted, err := sqlite.New(db, "token", sqlite.WithEncryption(testKey))
+	require.NoError(t, err)
+
+	data, err = encrypted.Get("john")
+
+	assert.Equal(t, "new-token", data)
+	require.NoError(t, err)
+}
+
+func TestStorage_WrongKey(t *testing.T) {
+	t.Parallel()
+
+	db := openTestDB(t)
+
+	s, err := sqlite.New(db, "service", sqlite.WithEncryption(testKey))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Set("test", "foobar"))
+
+	s, err = sqlite.New(db, "service",
+		sqlite.WithEncryption(moneyloverkeychain.StaticKey([]byte("fedcba9876543210fedcba9876543210"))),
+	)
+	require.NoError(t, err)
+
+	data, err := s.Get("test")
+
+	assert.Empty(t, data)
+	require.EqualError(t, err, "could not decrypt value: cipher: message authentication failed")
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	db := openTestDB(t)
+
+	require.NoError(t, sqlite.Migrate(db))
+	require.NoError(t, sqlite.Migrate(db))
+
+	var version int
+
+	err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	require.NoError(t, err)
+
+	assert.Equal(t, sqlite.SchemaVersion(), version)
+
+	// A database migrated by a newer version is refused.
+	_, err = db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, '')`, sqlite.SchemaVersion()+1)
+	require.NoError(t, err)
+
+	_, err = sqlite.New(db, "service")
+
+	assert.ErrorIs(t, err, sqlite.ErrUnsupportedSchema)
+}
+
+func TestOpen_Error(t *testing.T) {
+	t.Parallel()
+
+	_, err := sqlite.Open(filepath.Join(t.TempDir(), "unknown", "secrets.db"), "service")
+
+	require.Error(t, err)
+}
+
+func TestOpen_Permissions(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "secrets.db")
+
+	s, err := sqlite.Open(path, "service")
+	require.NoError(t, err)
+
+	defer s.Close() // nolint: errcheck
+
+	require.NoError(t, s.Set("test", "foobar"))
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+}
+
+func TestOpen_ConcurrentProcesses(t *testing.T) {
+	t.Parallel()
+
+	const writers = 4
+
+	path := filepath.Join(t.TempDir(), "secrets.db")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		// Every writer has its own connection, like separate processes.
+		s, err := sqlite.Open(path, "service")
+		require.NoError(t, err)
+
+		defer s.Close() // nolint: errcheck
+
+		key := fmt.Sprintf("key%d", i)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for n := 0; n < 10; n++ {
+				assert.NoError(t, s.Set(key, "foobar"))
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestStorage_KeyNotAvailableYet(t *testing.T) {
+	t.Parallel()
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+
+	s, err := sqlite.New(openTestDB(t), "service", sqlite.WithEncryption(moneyloverkeychain.KeyFromFile(keyFile)))
+	require.NoError(t, err)
+
+	err = s.Set("test", "foobar")
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// The key is read again once it is available.
+	require.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0o600))
+	require.NoError(t, s.Set("test", "foobar"))
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+}
@@ -1,7 +1,14 @@
 package moneyloverkeychain
 
 import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
 	"github.com/zalando/go-keyring"
+
+	"github.com/nhatthm/moneyloverkeychain/internal/fsutil"
 )
 
 var _ Storage = (*storage)(nil)
@@ -35,9 +42,105 @@ func (s *storage) Delete(user string) error {
 	return keyring.Delete(s.service, user)
 }
 
+// StorageOption configures the keychain storage.
+type StorageOption func(o *storageOptions)
+
+type storageOptions struct {
+	indexed bool
+	index   []IndexedStorageOption
+}
+
 // NewStorage creates a keychain storage.
-func NewStorage(service string) Storage {
-	return &storage{
-		service: service,
+func NewStorage(service string, options ...StorageOption) Storage {
+	o := storageOptions{}
+
+	for _, opt := range options {
+		opt(&o)
+	}
+
+	s := &storage{service: service}
+
+	if !o.indexed {
+		return s
+	}
+
+	var index []IndexedStorageOption
+
+	if l := newIndexLocker(service); l != nil {
+		index = append(index, WithIndexLocker(l))
+	}
+
+	return NewIndexedStorage(s, append(index, o.index...)...)
+}
+
+// WithKeyringIndex makes the storage implement Lister by maintaining an index entry of the service, see IndexedStorage,
+// because the system keyring can not enumerate the keys of a service. Every Set and Delete reads and writes the index.
+//
+// The processes of the user share a lock on the index in the user cache directory, which is only taken when the index
+// is updated. If the lock can not be acquired, such as when the home directory is read-only, the index is updated
+// without the lock from then on. The options configure the index, such as WithIndexLogger to report the failed updates.
+// The keys written before the index existed are listed after IndexedStorage.Repair.
+//
+// The index is a single secret, so it is subject to the size limit of the keyring, about 60 UUID keys on macOS and
+// Windows. Past that, the new keys are written but not listed, and the failed updates are logged.
+func WithKeyringIndex(options ...IndexedStorageOption) StorageOption {
+	return func(o *storageOptions) {
+		o.indexed = true
+		o.index = append(o.index, options...)
+	}
+}
+
+// indexLocker is a lock on a file that is created when the lock is first acquired. It falls back to no lock once the
+// file can not be locked.
+type indexLocker struct {
+	mu sync.Mutex
+
+	path     string
+	disabled bool
+	unlock   func() error
+}
+
+// Lock acquires the lock, or does nothing if the file can not be locked.
+func (l *indexLocker) Lock() error {
+	l.mu.Lock()
+
+	if l.disabled {
+		return nil
+	}
+
+	unlock, err := fsutil.Lock(l.path)
+	if err != nil {
+		l.disabled = true
+
+		return nil
 	}
+
+	l.unlock = unlock
+
+	return nil
+}
+
+// Unlock releases the lock.
+func (l *indexLocker) Unlock() error {
+	defer l.mu.Unlock()
+
+	unlock := l.unlock
+	l.unlock = nil
+
+	if unlock == nil {
+		return nil
+	}
+
+	return unlock()
+}
+
+// newIndexLocker creates the lock of the index in the user cache directory. It returns nil if there is no user cache
+// directory.
+func newIndexLocker(service string) Locker {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+
+	return &indexLocker{path: filepath.Join(dir, "moneyloverkeychain", url.PathEscape(service)+".lock")}
 }
@@ -1,6 +1,8 @@
 package moneyloverkeychain_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,6 +19,10 @@ func TestStorage(t *testing.T) {
 
 	s := moneyloverkeychain.NewStorage(service)
 
+	// The storage is not indexed by default.
+	_, ok := s.(moneyloverkeychain.Lister)
+	assert.False(t, ok)
+
 	test.Run(t, service, key, nil, func(t *testing.T) { //nolint: thelper
 		// Get not found.
 		data, err := s.Get(key)
@@ -43,3 +49,79 @@ func TestStorage(t *testing.T) {
 		assert.Equal(t, keyring.ErrNotFound, err)
 	})
 }
+
+func TestStorage_List(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	service := "storage"
+	key := "test"
+
+	s := moneyloverkeychain.NewStorage(service, moneyloverkeychain.WithKeyringIndex())
+
+	test.Run(t, service, key, nil, func(t *testing.T) { //nolint: thelper
+		l, ok := s.(moneyloverkeychain.Lister)
+		require.True(t, ok)
+
+		require.NoError(t, s.Set(key, "foobar"))
+
+		keys, err := l.List("")
+
+		assert.Contains(t, keys, key)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Delete(key))
+
+		keys, err = l.List("")
+
+		assert.NotContains(t, keys, key)
+		require.NoError(t, err)
+	})
+}
+
+func TestStorage_NoCacheDir(t *testing.T) {
+	service := "storage"
+	key := "test"
+
+	// The cache directory is a file, so the index can not be locked.
+	home := filepath.Join(t.TempDir(), "file")
+
+	require.NoError(t, os.WriteFile(home, nil, 0o600))
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", home)
+	t.Setenv("LocalAppData", home)
+
+	s := moneyloverkeychain.NewStorage(service, moneyloverkeychain.WithKeyringIndex())
+
+	test.Run(t, service, key, nil, func(t *testing.T) { //nolint: thelper
+		require.NoError(t, s.Set(key, "foobar"))
+
+		keys, err := s.(moneyloverkeychain.Lister).List("")
+
+		assert.Contains(t, keys, key)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Delete(key))
+	})
+}
+
+func TestStorage_LockOnWrite(t *testing.T) {
+	service := "storage"
+	key := "test"
+
+	dir := t.TempDir()
+
+	t.Setenv("XDG_CACHE_HOME", dir)
+
+	s := moneyloverkeychain.NewStorage(service, moneyloverkeychain.WithKeyringIndex())
+
+	assert.NoFileExists(t, filepath.Join(dir, "moneyloverkeychain", "storage.lock"))
+
+	test.Run(t, service, key, nil, func(t *testing.T) { //nolint: thelper
+		require.NoError(t, s.Set(key, "foobar"))
+
+		assert.FileExists(t, filepath.Join(dir, "moneyloverkeychain", "storage.lock"))
+
+		require.NoError(t, s.Delete(key))
+	})
+}
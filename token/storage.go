@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sync"
 
 	"github.com/bool64/ctxd"
 	"github.com/nhatthm/moneyloverapi"
@@ -31,12 +32,16 @@ type StorageOption func(s *Storage)
 
 // Storage provides token from keychain.
 type Storage struct {
-	storage moneyloverkeychain.Storage
+	storage     moneyloverkeychain.ContextStorage
+	conditional moneyloverkeychain.ConditionalStorage
+
+	mu        sync.Mutex
+	revisions map[string]uint64
 }
 
 // Get gets token from keychain.
 func (s *Storage) Get(ctx context.Context, key string) (auth.OAuthToken, error) {
-	data, err := s.storage.Get(key)
+	data, err := s.get(ctx, key)
 	if err != nil {
 		if errors.Is(err, keyring.ErrNotFound) {
 			return auth.OAuthToken{}, nil
@@ -55,18 +60,25 @@ func (s *Storage) Get(ctx context.Context, key string) (auth.OAuthToken, error)
 }
 
 // Set persists token to keychain.
+//
+// With a conditional keychain, the token is not persisted and the error is moneyloverkeychain.ErrConflict if it was
+// changed by someone else since it was last read.
 func (s *Storage) Set(ctx context.Context, key string, token auth.OAuthToken) error {
 	data, err := json.Marshal(token)
 	if err != nil {
 		return ctxd.WrapError(ctx, err, "could not marshal token")
 	}
 
-	return s.storage.Set(key, string(data))
+	return s.set(ctx, key, string(data))
 }
 
 // Delete deletes the token in keychain.
-func (s *Storage) Delete(_ context.Context, key string) error {
-	err := s.storage.Delete(key)
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.revisions, key)
+	s.mu.Unlock()
+
+	err := s.storage.Delete(ctx, key)
 	if err != nil && errors.Is(err, keyring.ErrNotFound) {
 		return nil
 	}
@@ -74,10 +86,83 @@ func (s *Storage) Delete(_ context.Context, key string) error {
 	return err
 }
 
+// Keys lists the keys of the tokens in keychain, such as the keychain storage with WithKeyringIndex. It fails with
+// moneyloverkeychain.ErrListNotSupported if the storage can not list its keys, such as the default keychain storage.
+func (s *Storage) Keys(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	l, ok := moneyloverkeychain.FromContextStorage(s.storage).(moneyloverkeychain.Lister)
+	if !ok {
+		return nil, moneyloverkeychain.ErrListNotSupported
+	}
+
+	return l.List("")
+}
+
+func (s *Storage) get(ctx context.Context, key string) (string, error) {
+	if s.conditional == nil {
+		return s.storage.Get(ctx, key)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	data, meta, err := s.conditional.GetWithMeta(key)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return "", err
+	}
+
+	// The revision of a token that does not exist is 0.
+	s.mu.Lock()
+	s.revisions[key] = meta.Revision
+	s.mu.Unlock()
+
+	return data, err
+}
+
+func (s *Storage) set(ctx context.Context, key, data string) error {
+	if s.conditional == nil {
+		return s.storage.Set(ctx, key, data)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	revision, ok := s.revisions[key]
+	s.mu.Unlock()
+
+	// The token was never read, its current revision is used.
+	if !ok {
+		_, meta, err := s.conditional.GetWithMeta(key)
+		if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+			return err
+		}
+
+		revision = meta.Revision
+	}
+
+	meta, err := s.conditional.SetIf(key, revision, data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.revisions[key] = meta.Revision
+	s.mu.Unlock()
+
+	return nil
+}
+
 // NewStorage returns keychain as a token storage.
 func NewStorage(options ...StorageOption) *Storage {
 	s := &Storage{
-		storage: moneyloverkeychain.NewStorage(tokenStorageService),
+		storage:   moneyloverkeychain.NewContextStorage(tokenStorageService),
+		revisions: make(map[string]uint64),
 	}
 
 	for _, o := range options {
@@ -89,8 +174,39 @@ func NewStorage(options ...StorageOption) *Storage {
 
 // WithKeyring sets keychain storage for Storage.
 func WithKeyring(storage moneyloverkeychain.Storage) StorageOption {
+	return func(s *Storage) {
+		s.storage = moneyloverkeychain.ToContextStorage(storage)
+		s.conditional = nil
+	}
+}
+
+// WithKeyringIndex sets the keychain storage with an index of its keys for Storage, so that the tokens written with it
+// are listed by Keys. See moneyloverkeychain.WithKeyringIndex.
+func WithKeyringIndex(options ...moneyloverkeychain.IndexedStorageOption) StorageOption {
+	return func(s *Storage) {
+		s.storage = moneyloverkeychain.NewContextStorage(tokenStorageService, moneyloverkeychain.WithKeyringIndex(options...))
+		s.conditional = nil
+	}
+}
+
+// WithContextKeyring sets a keychain storage that honours the context for Storage.
+func WithContextKeyring(storage moneyloverkeychain.ContextStorage) StorageOption {
 	return func(s *Storage) {
 		s.storage = storage
+		s.conditional = nil
+	}
+}
+
+// WithConditionalKeyring sets a keychain storage that writes the token only if it was not changed since it was last
+// read, so that a token refreshed by another process is not overwritten with an older one. The context is only checked
+// before the operations, they can not be interrupted.
+//
+// With moneyloverkeychain.EnvelopeStorage, a deleted token stays in the keychain as a tombstone that keeps its revision,
+// until it is purged with moneyloverkeychain.EnvelopeStorage.Purge.
+func WithConditionalKeyring(storage moneyloverkeychain.ConditionalStorage) StorageOption {
+	return func(s *Storage) {
+		s.storage = moneyloverkeychain.ToContextStorage(storage)
+		s.conditional = storage
 	}
 }
 
@@ -81,6 +81,25 @@ func TestTokenStorage_Get(t *testing.T) {
 	}
 }
 
+func TestTokenStorage_GetDeadline(t *testing.T) {
+	t.Parallel()
+
+	s := mock.MockStorage(func(s *mock.Storage) {
+		s.On("Get", tokenStorageKey).
+			WaitUntil(time.After(time.Second)).
+			Return("", keyring.ErrNotFound).
+			Maybe()
+	})(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	token, err := NewStorage(WithKeyring(s)).Get(ctx, tokenStorageKey)
+
+	assert.Equal(t, auth.OAuthToken{}, token)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
 func TestTokenStorage_GetKeyring(t *testing.T) {
 	expect := func(t *testing.T, s moneyloverkeychain.Storage) { //nolint: thelper
 		err := s.Set(tokenStorageKey, `{"access_token":"access","expires_at":"2020-01-02T03:04:05.000Z"}`)
@@ -194,3 +213,185 @@ func TestTokenStorage_DeleteKeyring(t *testing.T) {
 		assert.Equal(t, keyring.ErrNotFound, err)
 	})
 }
+
+func TestTokenStorage_MemoryStorage(t *testing.T) {
+	t.Parallel()
+
+	expectedToken := auth.OAuthToken{
+		AccessToken: "access",
+		ExpiresAt:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	s := moneyloverkeychain.NewMemoryStorage()
+	p := NewStorage(WithKeyring(s))
+
+	// Get not found.
+	token, err := p.Get(context.Background(), tokenStorageKey)
+
+	assert.Equal(t, auth.OAuthToken{}, token)
+	require.NoError(t, err)
+
+	// Set.
+	err = p.Set(context.Background(), tokenStorageKey, expectedToken)
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		tokenStorageKey: `{"access_token":"access","expires_at":"2020-01-02T03:04:05Z"}`,
+	}
+
+	assert.Equal(t, expected, s.Snapshot())
+
+	token, err = p.Get(context.Background(), tokenStorageKey)
+
+	assert.Equal(t, expectedToken, token)
+	require.NoError(t, err)
+
+	// Delete.
+	err = p.Delete(context.Background(), tokenStorageKey)
+	require.NoError(t, err)
+
+	err = p.Delete(context.Background(), tokenStorageKey)
+	require.NoError(t, err)
+
+	assert.Empty(t, s.Snapshot())
+}
+
+func TestTokenStorage_ConditionalKeyring(t *testing.T) {
+	t.Parallel()
+
+	oldToken := auth.OAuthToken{AccessToken: "old"}
+	newToken := auth.OAuthToken{AccessToken: "new"}
+
+	s := moneyloverkeychain.NewEnvelopeStorage(moneyloverkeychain.NewMemoryStorage())
+	first := NewStorage(WithConditionalKeyring(s))
+	second := NewStorage(WithConditionalKeyring(s))
+
+	// Both read the token before refreshing it.
+	token, err := first.Get(context.Background(), tokenStorageKey)
+
+	assert.Equal(t, auth.OAuthToken{}, token)
+	require.NoError(t, err)
+
+	token, err = second.Get(context.Background(), tokenStorageKey)
+
+	assert.Equal(t, auth.OAuthToken{}, token)
+	require.NoError(t, err)
+
+	// The first refresh wins.
+	err = first.Set(context.Background(), tokenStorageKey, newToken)
+	require.NoError(t, err)
+
+	err = second.Set(context.Background(), tokenStorageKey, oldToken)
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrConflict)
+
+	token, err = second.Get(context.Background(), tokenStorageKey)
+
+	assert.Equal(t, newToken, token)
+	require.NoError(t, err)
+
+	// Set after reading the latest token.
+	err = second.Set(context.Background(), tokenStorageKey, oldToken)
+	require.NoError(t, err)
+
+	token, err = first.Get(context.Background(), tokenStorageKey)
+
+	assert.Equal(t, oldToken, token)
+	require.NoError(t, err)
+
+	// Delete.
+	err = first.Delete(context.Background(), tokenStorageKey)
+	require.NoError(t, err)
+
+	token, err = second.Get(context.Background(), tokenStorageKey)
+
+	assert.Equal(t, auth.OAuthToken{}, token)
+	require.NoError(t, err)
+}
+
+func TestTokenStorage_Keys(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewMemoryStorage()
+	s.Restore(map[string]string{"john@example.org": "{}", tokenStorageKey: "{}"})
+
+	keys, err := NewStorage(WithKeyring(s)).Keys(context.Background())
+
+	assert.Equal(t, []string{"john@example.org", tokenStorageKey}, keys)
+	require.NoError(t, err)
+
+	// The storage can not list its keys.
+	keys, err = NewStorage(WithKeyring(mock.MockStorage()(t))).Keys(context.Background())
+
+	assert.Empty(t, keys)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrListNotSupported)
+}
+
+func TestTokenStorage_KeysKeyring(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	test.Run(t, tokenStorageService, tokenStorageKey, nil, func(t *testing.T) { //nolint: thelper
+		// The default keychain storage can not list its keys.
+		keys, err := NewStorage().Keys(context.Background())
+
+		assert.Empty(t, keys)
+		assert.ErrorIs(t, err, moneyloverkeychain.ErrListNotSupported)
+
+		p := NewStorage(WithKeyringIndex())
+
+		require.NoError(t, p.Set(context.Background(), tokenStorageKey, auth.OAuthToken{AccessToken: "access"}))
+
+		keys, err = p.Keys(context.Background())
+
+		assert.Contains(t, keys, tokenStorageKey)
+		require.NoError(t, err)
+
+		require.NoError(t, p.Delete(context.Background(), tokenStorageKey))
+
+		keys, err = p.Keys(context.Background())
+
+		assert.NotContains(t, keys, tokenStorageKey)
+		require.NoError(t, err)
+	})
+}
+
+func TestTokenStorage_ConditionalKeyringKeys(t *testing.T) {
+	t.Parallel()
+
+	s := NewStorage(WithConditionalKeyring(moneyloverkeychain.NewEnvelopeStorage(moneyloverkeychain.NewMemoryStorage())))
+
+	require.NoError(t, s.Set(context.Background(), "john@example.org", auth.OAuthToken{AccessToken: "john"}))
+	require.NoError(t, s.Set(context.Background(), tokenStorageKey, auth.OAuthToken{AccessToken: "default"}))
+	require.NoError(t, s.Delete(context.Background(), "john@example.org"))
+
+	keys, err := s.Keys(context.Background())
+
+	assert.Equal(t, []string{tokenStorageKey}, keys)
+	require.NoError(t, err)
+}
+
+func TestTokenStorage_ConditionalKeyringRecreated(t *testing.T) {
+	t.Parallel()
+
+	s := moneyloverkeychain.NewEnvelopeStorage(moneyloverkeychain.NewMemoryStorage())
+	first := NewStorage(WithConditionalKeyring(s))
+	second := NewStorage(WithConditionalKeyring(s))
+
+	require.NoError(t, first.Set(context.Background(), tokenStorageKey, auth.OAuthToken{AccessToken: "old"}))
+
+	_, err := first.Get(context.Background(), tokenStorageKey)
+	require.NoError(t, err)
+
+	// The second process logs out and in again.
+	require.NoError(t, second.Delete(context.Background(), tokenStorageKey))
+	require.NoError(t, second.Set(context.Background(), tokenStorageKey, auth.OAuthToken{AccessToken: "new"}))
+
+	err = first.Set(context.Background(), tokenStorageKey, auth.OAuthToken{AccessToken: "stale"})
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrConflict)
+
+	token, err := first.Get(context.Background(), tokenStorageKey)
+
+	assert.Equal(t, auth.OAuthToken{AccessToken: "new"}, token)
+	require.NoError(t, err)
+}
@@ -0,0 +1,2 @@
+// Package vault provides a storage in a HashiCorp Vault KV v2 secrets engine.
+package vault
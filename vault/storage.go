@@ -0,0 +1,642 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nhatthm/moneyloverkeychain"
+)
+
+const defaultField = "value"
+
+var (
+	_ moneyloverkeychain.Storage            = (*Storage)(nil)
+	_ moneyloverkeychain.ConditionalStorage = (*Storage)(nil)
+)
+
+// ErrCASMismatch indicates that the secret was changed by someone else while writing with check-and-set. It matches
+// moneyloverkeychain.ErrConflict.
+var ErrCASMismatch = fmt.Errorf("%w: check-and-set parameter did not match the current version",
+	moneyloverkeychain.ErrConflict,
+)
+
+// ErrNoAuth indicates that there is neither token nor AppRole to authenticate to Vault.
+var ErrNoAuth = errors.New("no vault token or approle")
+
+// Error is an error response from Vault.
+type Error struct {
+	StatusCode int
+	Errors     []string
+}
+
+// Error satisfies error interface.
+func (e *Error) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("vault: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("vault: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), strings.Join(e.Errors, ", "))
+}
+
+// Option configures Storage.
+type Option func(s *Storage)
+
+type appRole struct {
+	mount    string
+	roleID   string
+	secretID string
+}
+
+// Storage is a storage in a Vault KV v2 secrets engine. The service is the mount path of the engine, and the secret of a
+// key is stored in a field of the secret at the key path.
+//
+// The storage implements moneyloverkeychain.ConditionalStorage with the versions of the secrets as the revisions, and
+// the custom metadata of the secrets as the labels.
+type Storage struct {
+	client    *http.Client
+	address   string
+	mount     string
+	prefix    string
+	field     string
+	namespace string
+	cas       bool
+	destroy   bool
+	appRole   *appRole
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	versions    map[string]int
+}
+
+type secretData struct {
+	Data     map[string]interface{} `json:"data"`
+	Metadata struct {
+		Version int `json:"version"`
+	} `json:"metadata"`
+}
+
+type secretMetadata struct {
+	CurrentVersion int                        `json:"current_version"`
+	CreatedTime    time.Time                  `json:"created_time"`
+	CustomMetadata map[string]string          `json:"custom_metadata"`
+	Versions       map[string]versionMetadata `json:"versions"`
+}
+
+type versionMetadata struct {
+	CreatedTime  time.Time `json:"created_time"`
+	DeletionTime string    `json:"deletion_time"`
+	Destroyed    bool      `json:"destroyed"`
+}
+
+type writeResponse struct {
+	Version int `json:"version"`
+}
+
+type metadataRequest struct {
+	CustomMetadata map[string]string `json:"custom_metadata"`
+}
+
+type versionsRequest struct {
+	Versions []int `json:"versions"`
+}
+
+type writeRequest struct {
+	Data    map[string]string `json:"data"`
+	Options map[string]int    `json:"options,omitempty"`
+}
+
+// Set sets password in Vault for user.
+//
+// With check-and-set, the write fails with ErrCASMismatch if the secret was changed since this storage last read or
+// wrote it. If the secret was never read, its current version is used.
+func (s *Storage) Set(user, password string) error {
+	_, err := s.set(user, password)
+
+	return err
+}
+
+// Get gets the latest version of password from Vault.
+func (s *Storage) Get(user string) (string, error) {
+	password, _, err := s.read(user)
+
+	return password, err
+}
+
+// Delete deletes the latest version of secret from Vault, or all the versions and the metadata if WithDestroyOnDelete
+// is used.
+func (s *Storage) Delete(user string) error {
+	if s.destroy {
+		var meta secretMetadata
+
+		// The metadata exists even if the latest version is soft-deleted.
+		if err := s.do(http.MethodGet, s.url("metadata", user), nil, &meta); err != nil {
+			return err
+		}
+
+		if err := s.do(http.MethodDelete, s.url("metadata", user), nil, nil); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		delete(s.versions, user)
+		s.mu.Unlock()
+
+		return nil
+	}
+
+	var secret secretData
+
+	if err := s.do(http.MethodGet, s.url("data", user), nil, &secret); err != nil {
+		return err
+	}
+
+	return s.do(http.MethodDelete, s.url("data", user), nil, nil)
+}
+
+// GetWithMeta gets the latest version of password from Vault, with the version as the revision and the custom metadata
+// as the labels.
+func (s *Storage) GetWithMeta(user string) (string, moneyloverkeychain.Metadata, error) {
+	password, version, err := s.read(user)
+	if err != nil {
+		return "", moneyloverkeychain.Metadata{}, err
+	}
+
+	meta, err := s.metadata(user, version)
+	if err != nil {
+		return "", moneyloverkeychain.Metadata{}, err
+	}
+
+	return password, meta, nil
+}
+
+// SetWithMeta sets password in Vault for user, like Set, and replaces the custom metadata with the labels unless they
+// are nil.
+func (s *Storage) SetWithMeta(user, password string, labels map[string]string) (moneyloverkeychain.Metadata, error) {
+	version, err := s.set(user, password)
+	if err != nil {
+		return moneyloverkeychain.Metadata{}, err
+	}
+
+	if err := s.setLabels(user, labels); err != nil {
+		return moneyloverkeychain.Metadata{}, err
+	}
+
+	return s.metadata(user, version)
+}
+
+// SetIf sets password in Vault for user with check-and-set, if the latest version is the revision. Revision 0 means
+// that the secret does not exist or its latest version is deleted. The custom metadata is kept.
+func (s *Storage) SetIf(user string, revision uint64, password string) (moneyloverkeychain.Metadata, error) {
+	current, err := s.readMetadata(user)
+	if err != nil {
+		return moneyloverkeychain.Metadata{}, err
+	}
+
+	if actual := current.revision(); actual != revision {
+		return moneyloverkeychain.Metadata{}, &moneyloverkeychain.ConflictError{Key: user, Expected: revision, Actual: actual}
+	}
+
+	version, err := s.write(user, password, &current.CurrentVersion)
+	if errors.Is(err, ErrCASMismatch) {
+		return moneyloverkeychain.Metadata{}, s.conflict(user, revision)
+	}
+
+	if err != nil {
+		return moneyloverkeychain.Metadata{}, err
+	}
+
+	return s.metadata(user, version)
+}
+
+// DeleteIf deletes the latest version of secret from Vault if it is the revision. Only this version is deleted, or
+// destroyed if WithDestroyOnDelete is used, so a version written in the meantime is kept. The metadata is kept, so the
+// versions of a secret that is written again continue.
+func (s *Storage) DeleteIf(user string, revision uint64) error {
+	current, err := s.readMetadata(user)
+	if err != nil {
+		return err
+	}
+
+	actual := current.revision()
+
+	if actual == 0 {
+		return moneyloverkeychain.ErrNotFound
+	}
+
+	if actual != revision {
+		return &moneyloverkeychain.ConflictError{Key: user, Expected: revision, Actual: actual}
+	}
+
+	kind := "delete"
+
+	if s.destroy {
+		kind = "destroy"
+	}
+
+	return s.do(http.MethodPost, s.url(kind, user), versionsRequest{Versions: []int{int(revision)}}, nil)
+}
+
+// read reads the latest version of the secret.
+func (s *Storage) read(user string) (string, int, error) {
+	var secret secretData
+
+	if err := s.do(http.MethodGet, s.url("data", user), nil, &secret); err != nil {
+		return "", 0, err
+	}
+
+	s.setVersion(user, secret.Metadata.Version)
+
+	value, ok := secret.Data[s.field]
+	if !ok || value == nil {
+		return "", 0, moneyloverkeychain.ErrNotFound
+	}
+
+	password, ok := value.(string)
+	if !ok {
+		return "", 0, fmt.Errorf("vault: field %q of secret %q is not a string", s.field, user)
+	}
+
+	return password, secret.Metadata.Version, nil
+}
+
+// set writes a new version of the secret, with check-and-set if WithCAS is used.
+func (s *Storage) set(user, password string) (int, error) {
+	if !s.cas {
+		return s.write(user, password, nil)
+	}
+
+	version, err := s.casVersion(user)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.write(user, password, &version)
+}
+
+// write writes a new version of the secret, with check-and-set if cas is not nil, and returns the new version.
+func (s *Storage) write(user, password string, cas *int) (int, error) {
+	req := writeRequest{Data: map[string]string{s.field: password}}
+
+	if cas != nil {
+		req.Options = map[string]int{"cas": *cas}
+	}
+
+	var resp writeResponse
+
+	err := s.do(http.MethodPost, s.url("data", user), req, &resp)
+
+	var e *Error
+
+	if errors.As(err, &e) && e.StatusCode == http.StatusBadRequest && strings.Contains(strings.Join(e.Errors, ""), "check-and-set") {
+		return 0, fmt.Errorf("%w: %s", ErrCASMismatch, err.Error())
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	s.setVersion(user, resp.Version)
+
+	return resp.Version, nil
+}
+
+func (s *Storage) setLabels(user string, labels map[string]string) error {
+	if labels == nil {
+		return nil
+	}
+
+	return s.do(http.MethodPost, s.url("metadata", user), metadataRequest{CustomMetadata: labels}, nil)
+}
+
+// readMetadata reads the metadata of the secret. The metadata of a secret that was never written is empty.
+func (s *Storage) readMetadata(user string) (secretMetadata, error) {
+	var meta secretMetadata
+
+	if err := s.do(http.MethodGet, s.url("metadata", user), nil, &meta); err != nil &&
+		!errors.Is(err, moneyloverkeychain.ErrNotFound) {
+		return secretMetadata{}, err
+	}
+
+	return meta, nil
+}
+
+// metadata returns the metadata of a version of the secret.
+func (s *Storage) metadata(user string, version int) (moneyloverkeychain.Metadata, error) {
+	meta, err := s.readMetadata(user)
+	if err != nil {
+		return moneyloverkeychain.Metadata{}, err
+	}
+
+	result := moneyloverkeychain.Metadata{
+		CreatedAt: meta.CreatedTime,
+		UpdatedAt: meta.Versions[strconv.Itoa(version)].CreatedTime,
+		Revision:  uint64(version),
+	}
+
+	if len(meta.CustomMetadata) > 0 {
+		result.Labels = meta.CustomMetadata
+	}
+
+	return result, nil
+}
+
+// conflict returns the conflict of a write that expected the revision.
+func (s *Storage) conflict(user string, expected uint64) error {
+	meta, err := s.readMetadata(user)
+	if err != nil {
+		return err
+	}
+
+	return &moneyloverkeychain.ConflictError{Key: user, Expected: expected, Actual: meta.revision()}
+}
+
+// casVersion returns the version of the secret that was last seen, or its current version.
+func (s *Storage) casVersion(user string) (int, error) {
+	s.mu.Lock()
+	version, ok := s.versions[user]
+	s.mu.Unlock()
+
+	if ok {
+		return version, nil
+	}
+
+	meta, err := s.readMetadata(user)
+	if err != nil {
+		return 0, err
+	}
+
+	return meta.CurrentVersion, nil
+}
+
+func (s *Storage) setVersion(user string, version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.versions[user] = version
+}
+
+// revision returns the current version, or 0 if the secret does not exist or its latest version is deleted.
+func (m secretMetadata) revision() uint64 {
+	v := m.Versions[strconv.Itoa(m.CurrentVersion)]
+
+	if v.DeletionTime != "" || v.Destroyed {
+		return 0
+	}
+
+	return uint64(m.CurrentVersion)
+}
+
+func (s *Storage) url(kind, user string) string {
+	return fmt.Sprintf("%s/v1/%s/%s/%s", s.address, s.mount, kind, escapePath(s.prefix+user))
+}
+
+func (s *Storage) do(method, url string, body, result interface{}) error {
+	token, err := s.authenticate()
+	if err != nil {
+		return err
+	}
+
+	err = s.request(method, url, token, body, result)
+
+	var e *Error
+
+	// The AppRole token might be revoked or expired before its lease, so log in again.
+	if s.appRole != nil && errors.As(err, &e) && e.StatusCode == http.StatusForbidden {
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+
+		if token, err = s.authenticate(); err != nil {
+			return err
+		}
+
+		return s.request(method, url, token, body, result)
+	}
+
+	return err
+}
+
+func (s *Storage) authenticate() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.appRole == nil {
+		if s.token == "" {
+			return "", ErrNoAuth
+		}
+
+		return s.token, nil
+	}
+
+	if s.token != "" && (s.tokenExpiry.IsZero() || time.Now().Before(s.tokenExpiry)) {
+		return s.token, nil
+	}
+
+	var resp struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+
+	body := map[string]string{"role_id": s.appRole.roleID, "secret_id": s.appRole.secretID}
+	url := fmt.Sprintf("%s/v1/auth/%s/login", s.address, s.appRole.mount)
+
+	if err := s.request(http.MethodPost, url, "", body, &resp); err != nil {
+		return "", fmt.Errorf("could not log in with approle: %w", err)
+	}
+
+	s.token = resp.Auth.ClientToken
+	s.tokenExpiry = time.Time{}
+
+	if resp.Auth.LeaseDuration > 0 {
+		// Renew a bit before the lease expires.
+		lease := time.Duration(resp.Auth.LeaseDuration) * time.Second
+		s.tokenExpiry = time.Now().Add(lease - lease/10)
+	}
+
+	return s.token, nil
+}
+
+func (s *Storage) request(method, url, token string, body, result interface{}) error {
+	var r io.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, url, r)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() // nolint: errcheck
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return moneyloverkeychain.ErrNotFound
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+
+		_ = json.Unmarshal(data, &e) // nolint: errcheck
+
+		return &Error{StatusCode: resp.StatusCode, Errors: e.Errors}
+	}
+
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("could not unmarshal vault response: %w", err)
+	}
+
+	// The login response does not have data.
+	if envelope.Data == nil || string(envelope.Data) == "null" {
+		envelope.Data = data
+	}
+
+	if err := json.Unmarshal(envelope.Data, result); err != nil {
+		return fmt.Errorf("could not unmarshal vault response: %w", err)
+	}
+
+	return nil
+}
+
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// New creates a storage in the KV v2 secrets engine mounted at mount of the Vault server at address.
+func New(address, mount string, options ...Option) *Storage {
+	s := &Storage{
+		client:  http.DefaultClient,
+		address: strings.TrimSuffix(address, "/"),
+		mount:   strings.Trim(mount, "/"),
+		field:   defaultField,
+
+		versions: make(map[string]int),
+	}
+
+	for _, o := range options {
+		o(s)
+	}
+
+	return s
+}
+
+// WithToken authenticates with a Vault token.
+func WithToken(token string) Option {
+	return func(s *Storage) {
+		s.token = token
+	}
+}
+
+// WithAppRole authenticates with the AppRole auth method mounted at "approle".
+func WithAppRole(roleID, secretID string) Option {
+	return WithAppRoleMount("approle", roleID, secretID)
+}
+
+// WithAppRoleMount authenticates with the AppRole auth method mounted at mount.
+func WithAppRoleMount(mount, roleID, secretID string) Option {
+	return func(s *Storage) {
+		s.appRole = &appRole{
+			mount:    strings.Trim(mount, "/"),
+			roleID:   roleID,
+			secretID: secretID,
+		}
+	}
+}
+
+// WithPathPrefix prefixes the secret paths of the keys.
+func WithPathPrefix(prefix string) Option {
+	return func(s *Storage) {
+		s.prefix = prefix
+	}
+}
+
+// WithField sets the field of the secret that holds the password. Default is "value".
+func WithField(field string) Option {
+	return func(s *Storage) {
+		s.field = field
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace.
+func WithNamespace(namespace string) Option {
+	return func(s *Storage) {
+		s.namespace = namespace
+	}
+}
+
+// WithCAS writes with check-and-set, so a write fails if the secret was changed since its current version was read.
+func WithCAS() Option {
+	return func(s *Storage) {
+		s.cas = true
+	}
+}
+
+// WithDestroyOnDelete deletes all the versions and the metadata of the secret instead of the latest version.
+func WithDestroyOnDelete() Option {
+	return func(s *Storage) {
+		s.destroy = true
+	}
+}
+
+// WithHTTPClient sets the HTTP client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Storage) {
+		s.client = client
+	}
+}
@@ -0,0 +1,549 @@
+package vault_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nhatthm/moneyloverkeychain"
+	"github.com/nhatthm/moneyloverkeychain/vault"
+)
+
+const (
+	testToken    = "s.token"
+	testRoleID   = "role"
+	testSecretID = "secret"
+)
+
+type fakeSecret struct {
+	versions  []map[string]interface{}
+	created   []time.Time
+	deleted   map[int]bool
+	destroyed map[int]bool
+	custom    map[string]string
+}
+
+// fakeVault is a minimal stand-in of the Vault KV v2 API mounted at "secret".
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]*fakeSecret
+	tokens  map[string]bool
+	logins  int
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+
+	v := &fakeVault{
+		secrets: make(map[string]*fakeSecret),
+		tokens:  map[string]bool{testToken: true},
+	}
+
+	srv := httptest.NewServer(v)
+
+	t.Cleanup(srv.Close)
+
+	return v, srv
+}
+
+func (v *fakeVault) revokeAll() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.tokens = make(map[string]bool)
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		v.login(w, r)
+
+		return
+	}
+
+	if !v.tokens[r.Header.Get("X-Vault-Token")] {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+		v.data(w, r, strings.TrimPrefix(r.URL.Path, "/v1/secret/data/"))
+
+	case strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/"):
+		v.metadata(w, r, strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/"))
+
+	case strings.HasPrefix(r.URL.Path, "/v1/secret/delete/"):
+		v.deleteVersions(w, r, strings.TrimPrefix(r.URL.Path, "/v1/secret/delete/"), false)
+
+	case strings.HasPrefix(r.URL.Path, "/v1/secret/destroy/"):
+		v.deleteVersions(w, r, strings.TrimPrefix(r.URL.Path, "/v1/secret/destroy/"), true)
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func (v *fakeVault) login(w http.ResponseWriter, r *http.Request) {
+	var req map[string]string
+
+	_ = json.NewDecoder(r.Body).Decode(&req) // nolint: errcheck
+
+	if req["role_id"] != testRoleID || req["secret_id"] != testSecretID {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid role or secret ID"}})
+
+		return
+	}
+
+	v.logins++
+	token := "s.approle" + strings.Repeat("x", v.logins)
+	v.tokens[token] = true
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"auth": map[string]interface{}{"client_token": token, "lease_duration": 3600},
+	})
+}
+
+func (v *fakeVault) data(w http.ResponseWriter, r *http.Request, path string) {
+	secret := v.secrets[path]
+
+	switch r.Method {
+	case http.MethodGet:
+		if secret == nil || secret.deleted[len(secret.versions)] || secret.destroyed[len(secret.versions)] {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     secret.versions[len(secret.versions)-1],
+				"metadata": map[string]interface{}{"version": len(secret.versions)},
+			},
+		})
+
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Data    map[string]interface{} `json:"data"`
+			Options map[string]int         `json:"options"`
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&req) // nolint: errcheck
+
+		if secret == nil {
+			secret = &fakeSecret{deleted: make(map[int]bool), destroyed: make(map[int]bool)}
+		}
+
+		if cas, ok := req.Options["cas"]; ok && cas != len(secret.versions) {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"errors": []string{"check-and-set parameter did not match the current version"},
+			})
+
+			return
+		}
+
+		secret.versions = append(secret.versions, req.Data)
+		secret.created = append(secret.created, time.Now().UTC())
+		v.secrets[path] = secret
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{"version": len(secret.versions)},
+		})
+
+	case http.MethodDelete:
+		if secret != nil {
+			secret.deleted[len(secret.versions)] = true
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (v *fakeVault) metadata(w http.ResponseWriter, r *http.Request, path string) {
+	secret := v.secrets[path]
+
+	switch r.Method {
+	case http.MethodGet:
+		if secret == nil {
+			writeJSON(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+
+			return
+		}
+
+		versions := make(map[string]interface{}, len(secret.versions))
+
+		for i := range secret.versions {
+			version := map[string]interface{}{
+				"created_time":  secret.created[i].Format(time.RFC3339Nano),
+				"deletion_time": "",
+				"destroyed":     secret.destroyed[i+1],
+			}
+
+			if secret.deleted[i+1] {
+				version["deletion_time"] = secret.created[i].Format(time.RFC3339Nano)
+			}
+
+			versions[strconv.Itoa(i+1)] = version
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{
+				"current_version": len(secret.versions),
+				"created_time":    secret.created[0].Format(time.RFC3339Nano),
+				"custom_metadata": secret.custom,
+				"versions":        versions,
+			},
+		})
+
+	case http.MethodPost:
+		var req struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&req) // nolint: errcheck
+
+		if secret != nil {
+			secret.custom = req.CustomMetadata
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		delete(v.secrets, path)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (v *fakeVault) deleteVersions(w http.ResponseWriter, r *http.Request, path string, destroy bool) {
+	var req struct {
+		Versions []int `json:"versions"`
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&req) // nolint: errcheck
+
+	if secret := v.secrets[path]; secret != nil {
+		for _, version := range req.Versions {
+			if destroy {
+				secret.destroyed[version] = true
+			} else {
+				secret.deleted[version] = true
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(body) // nolint: errcheck
+}
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	v, srv := newFakeVault(t)
+	s := vault.New(srv.URL, "secret", vault.WithToken(testToken), vault.WithPathPrefix("moneyloverapi/token/"))
+
+	// Get not found.
+	data, err := s.Get("user@example.org")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Set.
+	err = s.Set("user@example.org", "foobar")
+	require.NoError(t, err)
+
+	data, err = s.Get("user@example.org")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"value": "foobar"}, v.secrets["moneyloverapi/token/user@example.org"].versions[0])
+
+	// Read latest.
+	err = s.Set("user@example.org", "foobaz")
+	require.NoError(t, err)
+
+	data, err = s.Get("user@example.org")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+
+	// Delete.
+	err = s.Delete("user@example.org")
+	require.NoError(t, err)
+
+	data, err = s.Get("user@example.org")
+
+	assert.Empty(t, data)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	err = s.Delete("user@example.org")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+	// Only the latest version is deleted.
+	assert.Len(t, v.secrets["moneyloverapi/token/user@example.org"].versions, 2)
+}
+
+func TestStorage_DestroyOnDelete(t *testing.T) {
+	t.Parallel()
+
+	v, srv := newFakeVault(t)
+	s := vault.New(srv.URL, "secret", vault.WithToken(testToken), vault.WithDestroyOnDelete(), vault.WithField("password"))
+
+	require.NoError(t, s.Set("test", "foobar"))
+
+	assert.Equal(t, map[string]interface{}{"password": "foobar"}, v.secrets["test"].versions[0])
+
+	require.NoError(t, s.Delete("test"))
+
+	assert.Empty(t, v.secrets)
+}
+
+func TestStorage_DestroyOnDeleteSoftDeleted(t *testing.T) {
+	t.Parallel()
+
+	v, srv := newFakeVault(t)
+	s := vault.New(srv.URL, "secret", vault.WithToken(testToken))
+
+	require.NoError(t, s.Set("test", "foobar"))
+	require.NoError(t, s.Delete("test"))
+
+	// The latest version is soft-deleted, the metadata is still destroyed.
+	s = vault.New(srv.URL, "secret", vault.WithToken(testToken), vault.WithDestroyOnDelete())
+
+	require.NoError(t, s.Delete("test"))
+
+	assert.Empty(t, v.secrets)
+
+	err := s.Delete("test")
+
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+}
+
+func TestStorage_CAS(t *testing.T) {
+	t.Parallel()
+
+	_, srv := newFakeVault(t)
+
+	s1 := vault.New(srv.URL, "secret", vault.WithToken(testToken), vault.WithCAS())
+	s2 := vault.New(srv.URL, "secret", vault.WithToken(testToken), vault.WithCAS())
+
+	// Create.
+	require.NoError(t, s1.Set("test", "foobar"))
+
+	// Both read the same version.
+	_, err := s1.Get("test")
+	require.NoError(t, err)
+
+	_, err = s2.Get("test")
+	require.NoError(t, err)
+
+	// The first write wins.
+	require.NoError(t, s2.Set("test", "foobaz"))
+
+	err = s1.Set("test", "fooqux")
+
+	assert.ErrorIs(t, err, vault.ErrCASMismatch)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrConflict)
+
+	// After reading again, the write succeeds.
+	data, err := s1.Get("test")
+
+	assert.Equal(t, "foobaz", data)
+	require.NoError(t, err)
+
+	require.NoError(t, s1.Set("test", "fooqux"))
+
+	// A storage that has never seen the secret writes on top of the current version.
+	require.NoError(t, vault.New(srv.URL, "secret", vault.WithToken(testToken), vault.WithCAS()).Set("test", "quux"))
+}
+
+func TestStorage_SetIf(t *testing.T) {
+	t.Parallel()
+
+	_, srv := newFakeVault(t)
+	s := vault.New(srv.URL, "secret", vault.WithToken(testToken))
+
+	// Create.
+	meta, err := s.SetIf("test", 0, "foobar")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), meta.Revision)
+	assert.False(t, meta.CreatedAt.IsZero())
+	assert.Equal(t, meta.CreatedAt, meta.UpdatedAt)
+
+	// Conflict.
+	_, err = s.SetIf("test", 0, "foobaz")
+
+	require.EqualError(t, err, `revision conflict: "test" is at revision 1, expected 0`)
+	assert.ErrorIs(t, err, moneyloverkeychain.ErrConflict)
+
+	// Update with labels, then keep them.
+	meta, err = s.SetWithMeta("test", "foobaz", map[string]string{"owner": "reporting"})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(2), meta.Revision)
+
+	meta, err = s.SetIf("test", 2, "fooqux")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(3), meta.Revision)
+
+	data, meta, err := s.GetWithMeta("test")
+
+	assert.Equal(t, "fooqux", data)
+	assert.Equal(t, uint64(3), meta.Revision)
+	assert.Equal(t, map[string]string{"owner": "reporting"}, meta.Labels)
+	require.NoError(t, err)
+
+	// Stale.
+	_, err = s.SetIf("test", 2, "stale")
+
+	require.EqualError(t, err, `revision conflict: "test" is at revision 3, expected 2`)
+}
+
+func TestStorage_DeleteIf(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		scenario string
+		options  []vault.Option
+	}{
+		{
+			scenario: "delete",
+		},
+		{
+			scenario: "destroy",
+			options:  []vault.Option{vault.WithDestroyOnDelete()},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			_, srv := newFakeVault(t)
+			s := vault.New(srv.URL, "secret", append(tc.options, vault.WithToken(testToken))...)
+
+			// Delete not found.
+			err := s.DeleteIf("test", 0)
+
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+			// Conflict.
+			_, err = s.SetIf("test", 0, "foobar")
+			require.NoError(t, err)
+
+			err = s.DeleteIf("test", 2)
+
+			require.EqualError(t, err, `revision conflict: "test" is at revision 1, expected 2`)
+
+			// Delete.
+			require.NoError(t, s.DeleteIf("test", 1))
+
+			_, meta, err := s.GetWithMeta("test")
+
+			assert.Equal(t, moneyloverkeychain.Metadata{}, meta)
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrNotFound)
+
+			// A writer that read the deleted secret does not bring it back.
+			_, err = s.SetIf("test", 1, "stale")
+
+			require.EqualError(t, err, `revision conflict: "test" is at revision 0, expected 1`)
+
+			// The versions continue after the secret is written again.
+			meta, err = s.SetIf("test", 0, "foobaz")
+			require.NoError(t, err)
+
+			assert.Equal(t, uint64(2), meta.Revision)
+
+			_, err = s.SetIf("test", 1, "stale")
+
+			assert.ErrorIs(t, err, moneyloverkeychain.ErrConflict)
+		})
+	}
+}
+
+func TestStorage_AppRole(t *testing.T) {
+	t.Parallel()
+
+	v, srv := newFakeVault(t)
+	s := vault.New(srv.URL, "secret", vault.WithAppRole(testRoleID, testSecretID))
+
+	require.NoError(t, s.Set("test", "foobar"))
+
+	data, err := s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, v.logins)
+
+	// The token is revoked, log in again.
+	v.revokeAll()
+
+	data, err = s.Get("test")
+
+	assert.Equal(t, "foobar", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, v.logins)
+}
+
+func TestStorage_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, srv := newFakeVault(t)
+
+	testCases := []struct {
+		scenario      string
+		storage       *vault.Storage
+		expectedError string
+	}{
+		{
+			scenario:      "no auth",
+			storage:       vault.New(srv.URL, "secret"),
+			expectedError: "no vault token or approle",
+		},
+		{
+			scenario:      "wrong token",
+			storage:       vault.New(srv.URL, "secret", vault.WithToken("unknown")),
+			expectedError: "vault: 403 Forbidden: permission denied",
+		},
+		{
+			scenario:      "wrong approle",
+			storage:       vault.New(srv.URL, "secret", vault.WithAppRole(testRoleID, "unknown")),
+			expectedError: "could not log in with approle: vault: 400 Bad Request: invalid role or secret ID",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.scenario, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tc.storage.Get("test")
+
+			require.EqualError(t, err, tc.expectedError)
+		})
+	}
+}